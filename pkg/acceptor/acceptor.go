@@ -0,0 +1,78 @@
+// Package acceptor describes the notes counted by a cash deposit acceptor.
+package acceptor
+
+import "atm/pkg/model"
+
+// Status is how the acceptor classified a note.
+type Status int
+
+const (
+	Genuine Status = iota
+	// Unrecognised notes could not be identified and are returned.
+	Unrecognised
+	// Suspect notes look counterfeit and are returned.
+	Suspect
+)
+
+func (s Status) String() string {
+	switch s {
+	case Genuine:
+		return "genuine"
+	case Unrecognised:
+		return "unrecognised"
+	case Suspect:
+		return "suspect"
+	default:
+		return "unknown"
+	}
+}
+
+type Note struct {
+	// Denomination is the value of the note in minor units. It is zero for
+	// unrecognised notes.
+	Denomination int64
+	Status       Status
+}
+
+// Bundle is the result of counting the notes the customer inserted. Only
+// Genuine notes are held in escrow.
+type Bundle struct {
+	Currency model.Currency
+	Notes    []Note
+}
+
+// Total returns the value of the genuine notes.
+func (b Bundle) Total() model.Money {
+	total := model.NewMoney(0, b.Currency)
+	for _, n := range b.Notes {
+		if n.Status == Genuine {
+			total.Amount += n.Denomination
+		}
+	}
+
+	return total
+}
+
+// Count returns the number of notes with the given status.
+func (b Bundle) Count(status Status) int {
+	var count int
+	for _, n := range b.Notes {
+		if n.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Denominations returns the number of genuine notes per denomination.
+func (b Bundle) Denominations() map[int64]int {
+	notes := make(map[int64]int)
+	for _, n := range b.Notes {
+		if n.Status == Genuine {
+			notes[n.Denomination]++
+		}
+	}
+
+	return notes
+}
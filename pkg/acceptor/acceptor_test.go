@@ -0,0 +1,28 @@
+package acceptor
+
+import (
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	b := Bundle{
+		Currency: "USD",
+		Notes: []Note{
+			{Denomination: 2000, Status: Genuine},
+			{Denomination: 5000, Status: Genuine},
+			{Denomination: 2000, Status: Genuine},
+			{Status: Unrecognised},
+			{Denomination: 10000, Status: Suspect},
+		},
+	}
+
+	require.Equal(t, model.NewMoney(9000, "USD"), b.Total())
+	require.Equal(t, 3, b.Count(Genuine))
+	require.Equal(t, 1, b.Count(Unrecognised))
+	require.Equal(t, 1, b.Count(Suspect))
+	require.Equal(t, map[int64]int{2000: 2, 5000: 1}, b.Denominations())
+
+	require.True(t, Bundle{Currency: "USD"}.Total().IsZero())
+}
@@ -0,0 +1,139 @@
+// Package amountpolicy decides whether a deposit or withdrawal amount is
+// acceptable at a terminal.
+package amountpolicy
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+)
+
+// Policy holds a terminal's amount rules. The zero Policy accepts any
+// positive amount.
+type Policy struct {
+	// Currency, if set, is the only currency the terminal accepts.
+	Currency model.Currency
+	// Denominations are the note values the terminal dispenses, in minor
+	// units. Withdrawals must be a sum of them. Empty allows any amount.
+	Denominations []int64
+	// MaxWithdrawal and MaxDeposit cap a single transaction. A zero amount
+	// means no maximum.
+	MaxWithdrawal model.Money
+	MaxDeposit    model.Money
+}
+
+// ValidateWithdrawal returns an error carrying errorcode.NonPositiveAmount,
+// errorcode.AmountNotDispensable or errorcode.AmountAboveMaximum if amount
+// cannot be withdrawn.
+func (p Policy) ValidateWithdrawal(amount model.Money) error {
+	if err := p.validate(amount, p.MaxWithdrawal); err != nil {
+		return err
+	}
+	if !Dispensable(amount.Amount, p.Denominations) {
+		return errorcode.Wrap(errorcode.AmountNotDispensable, fmt.Errorf("%s with notes of %v", amount, p.Denominations))
+	}
+
+	return nil
+}
+
+// ValidateDeposit returns an error carrying errorcode.NonPositiveAmount or
+// errorcode.AmountAboveMaximum if amount cannot be deposited.
+func (p Policy) ValidateDeposit(amount model.Money) error {
+	return p.validate(amount, p.MaxDeposit)
+}
+
+func (p Policy) validate(amount, maximum model.Money) error {
+	if p.Currency != "" && amount.Currency != p.Currency {
+		return errorcode.Wrap(errorcode.CurrencyMismatch, fmt.Errorf("%s and %s", amount.Currency, p.Currency))
+	}
+	if !amount.IsPositive() {
+		return errorcode.Wrap(errorcode.NonPositiveAmount, fmt.Errorf("%s", amount))
+	}
+	if maximum.IsZero() {
+		return nil
+	}
+
+	cmp, err := amount.Cmp(maximum)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return errorcode.Wrap(errorcode.AmountAboveMaximum, fmt.Errorf("%s above %s", amount, maximum))
+	}
+
+	return nil
+}
+
+// Dispensable reports whether amount can be made up from any number of
+// notes of the given denominations. Non-positive denominations are ignored
+// and an empty set allows any amount.
+func Dispensable(amount int64, denominations []int64) bool {
+	var notes []int64
+	for _, d := range denominations {
+		if d > 0 {
+			notes = append(notes, d)
+		}
+	}
+	if len(notes) == 0 {
+		return true
+	}
+	if amount < 0 {
+		return false
+	}
+
+	// Work in units of the greatest common divisor, then find the smallest
+	// reachable sum in each residue class modulo the smallest note. amount is
+	// dispensable iff it is at least the smallest sum in its class.
+	g := notes[0]
+	for _, d := range notes[1:] {
+		g = gcd(g, d)
+	}
+	if amount%g != 0 {
+		return false
+	}
+	amount /= g
+
+	smallest := notes[0] / g
+	for _, d := range notes {
+		smallest = min(smallest, d/g)
+	}
+
+	const unreachable = int64(-1)
+	reach := make([]int64, smallest)
+	for i := range reach {
+		reach[i] = unreachable
+	}
+	reach[0] = 0
+
+	for changed := true; changed; {
+		changed = false
+		for _, base := range reach {
+			if base == unreachable {
+				continue
+			}
+			for _, d := range notes {
+				next := base + d/g
+				if next < base {
+					continue
+				}
+				to := next % smallest
+				if reach[to] == unreachable || next < reach[to] {
+					reach[to] = next
+					changed = true
+				}
+			}
+		}
+	}
+
+	least := reach[amount%smallest]
+
+	return least != unreachable && amount >= least
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
@@ -0,0 +1,70 @@
+package amountpolicy
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+func TestValidateWithdrawal(t *testing.T) {
+	p := Policy{
+		Currency:      "USD",
+		Denominations: []int64{2000, 5000},
+		MaxWithdrawal: usd(50000),
+	}
+
+	for _, amount := range []int64{2000, 4000, 5000, 6000, 7000, 8000, 50000} {
+		require.NoError(t, p.ValidateWithdrawal(usd(amount)), amount)
+	}
+
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(0)), errorcode.ErrNonPositiveAmount)
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(-2000)), errorcode.ErrNonPositiveAmount)
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(3000)), errorcode.ErrAmountNotDispensable)
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(2050)), errorcode.ErrAmountNotDispensable)
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(52000)), errorcode.ErrAmountAboveMaximum)
+	require.ErrorIs(t, p.ValidateWithdrawal(model.NewMoney(2000, "EUR")), errorcode.ErrCurrencyMismatch)
+}
+
+func TestValidateDeposit(t *testing.T) {
+	p := Policy{Denominations: []int64{2000}, MaxDeposit: usd(100000)}
+
+	require.NoError(t, p.ValidateDeposit(usd(1234)))
+	require.ErrorIs(t, p.ValidateDeposit(usd(0)), errorcode.ErrNonPositiveAmount)
+	require.ErrorIs(t, p.ValidateDeposit(usd(100001)), errorcode.ErrAmountAboveMaximum)
+}
+
+func TestZeroPolicy(t *testing.T) {
+	var p Policy
+
+	require.NoError(t, p.ValidateWithdrawal(usd(1)))
+	require.NoError(t, p.ValidateDeposit(model.NewMoney(1, "JPY")))
+	require.ErrorIs(t, p.ValidateWithdrawal(usd(-1)), errorcode.ErrNonPositiveAmount)
+}
+
+func TestDispensable(t *testing.T) {
+	for _, tc := range []struct {
+		amount        int64
+		denominations []int64
+		expected      bool
+	}{
+		{amount: 0, denominations: []int64{20}, expected: true},
+		{amount: 30, denominations: []int64{20, 50}, expected: false},
+		{amount: 60, denominations: []int64{20, 50}, expected: true},
+		{amount: 110, denominations: []int64{20, 50}, expected: true},
+		{amount: 130, denominations: []int64{20, 50}, expected: true},
+		{amount: 10, denominations: []int64{20, 50}, expected: false},
+		{amount: 7, denominations: []int64{3, 5}, expected: false},
+		{amount: 8, denominations: []int64{3, 5}, expected: true},
+		{amount: 1_000_003, denominations: []int64{3, 5}, expected: true},
+		{amount: 15, denominations: []int64{10}, expected: false},
+		{amount: 15, denominations: nil, expected: true},
+		{amount: -20, denominations: []int64{20}, expected: false},
+	} {
+		require.Equal(t, tc.expected, Dispensable(tc.amount, tc.denominations), "%d in %v", tc.amount, tc.denominations)
+	}
+}
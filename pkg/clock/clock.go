@@ -0,0 +1,18 @@
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Real returns a Clock backed by time.Now.
+func Real() Clock {
+	return realClock{}
+}
@@ -1,51 +1,247 @@
 package context
 
-import "atm/pkg/model"
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"sync"
+	"time"
+)
 
+// AtmContext holds the state of one customer session. It is safe for
+// concurrent use; callers that need several calls to be atomic must
+// serialise them themselves.
 type AtmContext struct {
-	card       *model.Card
-	strValues  map[string]string
-	boolValues map[string]bool
+	mu sync.Mutex
+
+	state       State
+	card        *model.Card
+	accountID   string
+	pinAttempts int
+
+	lastActivity time.Time
 }
 
 func NewAtmContext() *AtmContext {
-	return &AtmContext{
-		strValues:  make(map[string]string),
-		boolValues: make(map[string]bool),
+	return &AtmContext{state: Idle}
+}
+
+func (ctx *AtmContext) State() State {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.state
+}
+
+// Touch records customer activity at now.
+func (ctx *AtmContext) Touch(now time.Time) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.lastActivity = now
+}
+
+// IdleFor returns how long the session has been without activity at now.
+func (ctx *AtmContext) IdleFor(now time.Time) time.Duration {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return now.Sub(ctx.lastActivity)
+}
+
+// Transition moves the session to state to, or returns an error wrapping
+// errorcode.ErrIllegalTransition if that transition is not declared.
+func (ctx *AtmContext) Transition(to State) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.transition(to)
+}
+
+func (ctx *AtmContext) transition(to State) error {
+	if !CanTransition(ctx.state, to) {
+		return errorcode.Wrap(errorcode.IllegalTransition, fmt.Errorf("%s -> %s", ctx.state, to))
+	}
+
+	ctx.state = to
+
+	return nil
+}
+
+// Require checks that the session has reached at least state min and returns
+// the error code describing the first missing step otherwise.
+func (ctx *AtmContext) Require(min State) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	switch ctx.state {
+	case Idle:
+		return errorcode.ErrNoCardFound
+	case Ejecting:
+		return errorcode.ErrCardEjecting
+	case InTransaction:
+		return errorcode.ErrTransactionInProgress
+	}
+
+	if ctx.state >= min {
+		return nil
+	}
+
+	switch ctx.state {
+	case CardInserted:
+		return errorcode.ErrPinNumberNotValidated
+	default:
+		return errorcode.ErrNoAccountSelected
 	}
 }
 
 func (ctx *AtmContext) ViewCard() *model.Card {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
 	return ctx.card
 }
 
-func (ctx *AtmContext) SetCard(card model.Card) {
-	ctx.boolValues[string(CardInserted)] = true
+func (ctx *AtmContext) SetCard(card model.Card) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := ctx.transition(CardInserted); err != nil {
+		return err
+	}
+
 	ctx.card = &card
+
+	return nil
 }
 
 func (ctx *AtmContext) HasCardInserted() bool {
-	return ctx.boolValues[string(CardInserted)] && ctx.card != nil
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.state != Idle && ctx.card != nil
 }
 
-func (ctx *AtmContext) SetAccountID(accountID string) {
-	ctx.strValues[string(AccountID)] = accountID
+func (ctx *AtmContext) SetPinNumValid() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.transition(Authenticated)
+}
+
+func (ctx *AtmContext) IsPinNumValidated() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.state >= Authenticated && ctx.state <= InTransaction
+}
+
+// FailPinAttempt records a wrong PIN for the current card.
+func (ctx *AtmContext) FailPinAttempt() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.pinAttempts++
+}
+
+func (ctx *AtmContext) PinAttempts() int {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.pinAttempts
+}
+
+func (ctx *AtmContext) SetAccountID(accountID string) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := ctx.transition(AccountSelected); err != nil {
+		return err
+	}
+
+	ctx.accountID = accountID
+
+	return nil
 }
 
 func (ctx *AtmContext) GetAccountID() string {
-	return ctx.strValues[string(AccountID)]
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.accountID
 }
 
-func (ctx *AtmContext) SetPinNumValid(validated bool) {
-	ctx.boolValues[string(PinNumIsValidated)] = validated
+func (ctx *AtmContext) BeginTransaction() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.transition(InTransaction)
 }
 
-func (ctx *AtmContext) IsPinNumValidated() bool {
-	return ctx.boolValues[string(PinNumIsValidated)]
+func (ctx *AtmContext) EndTransaction() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.transition(AccountSelected)
 }
 
+// BeginEject moves the session to Ejecting and drops the selected account.
+// The card stays in the session until CompleteEject or Retain is called.
+func (ctx *AtmContext) BeginEject() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if ctx.state == Ejecting {
+		return nil
+	}
+	if err := ctx.transition(Ejecting); err != nil {
+		return err
+	}
+
+	ctx.accountID = ""
+
+	return nil
+}
+
+func (ctx *AtmContext) CompleteEject() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := ctx.transition(Idle); err != nil {
+		return err
+	}
+
+	ctx.clear()
+
+	return nil
+}
+
+// Retain ends the session after the card has been captured by the terminal.
+func (ctx *AtmContext) Retain() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := ctx.transition(Idle); err != nil {
+		return err
+	}
+
+	ctx.clear()
+
+	return nil
+}
+
+// Clear abandons the session and returns it to Idle from any state.
 func (ctx *AtmContext) Clear() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.state = Idle
+	ctx.clear()
+}
+
+func (ctx *AtmContext) clear() {
 	ctx.card = nil
-	ctx.strValues = make(map[string]string)
-	ctx.boolValues = make(map[string]bool)
+	ctx.accountID = ""
+	ctx.pinAttempts = 0
+	ctx.lastActivity = time.Time{}
 }
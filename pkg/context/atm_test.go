@@ -0,0 +1,95 @@
+package context
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	ctx := NewAtmContext()
+	require.Equal(t, Idle, ctx.State())
+
+	require.NoError(t, ctx.SetCard(model.Card{HolderName: "test user", Number: "4111111111111111"}))
+	require.Equal(t, CardInserted, ctx.State())
+	require.True(t, ctx.HasCardInserted())
+
+	require.NoError(t, ctx.SetPinNumValid())
+	require.Equal(t, Authenticated, ctx.State())
+	require.True(t, ctx.IsPinNumValidated())
+
+	require.NoError(t, ctx.SetAccountID("test_account_1"))
+	require.Equal(t, AccountSelected, ctx.State())
+
+	require.NoError(t, ctx.BeginTransaction())
+	require.Equal(t, InTransaction, ctx.State())
+	require.NoError(t, ctx.EndTransaction())
+	require.Equal(t, AccountSelected, ctx.State())
+
+	require.NoError(t, ctx.BeginEject())
+	require.Equal(t, Ejecting, ctx.State())
+	require.True(t, ctx.HasCardInserted())
+	require.False(t, ctx.IsPinNumValidated())
+
+	require.NoError(t, ctx.CompleteEject())
+	require.Equal(t, Idle, ctx.State())
+	require.False(t, ctx.HasCardInserted())
+	require.Empty(t, ctx.GetAccountID())
+}
+
+func TestIllegalTransition(t *testing.T) {
+	ctx := NewAtmContext()
+
+	err := ctx.SetPinNumValid()
+	require.ErrorIs(t, err, errorcode.ErrIllegalTransition)
+	require.EqualError(t, err, "illegal session state transition: idle -> authenticated")
+	require.Equal(t, Idle, ctx.State())
+
+	require.NoError(t, ctx.SetCard(model.Card{HolderName: "test user", Number: "4111111111111111"}))
+	require.ErrorIs(t, ctx.SetCard(model.Card{}), errorcode.ErrIllegalTransition)
+	require.ErrorIs(t, ctx.SetAccountID("test_account_1"), errorcode.ErrIllegalTransition)
+	require.ErrorIs(t, ctx.BeginTransaction(), errorcode.ErrIllegalTransition)
+	require.Equal(t, CardInserted, ctx.State())
+}
+
+func TestRequire(t *testing.T) {
+	ctx := NewAtmContext()
+	require.ErrorIs(t, ctx.Require(CardInserted), errorcode.ErrNoCardFound)
+
+	_ = ctx.SetCard(model.Card{HolderName: "test user", Number: "4111111111111111"})
+	require.NoError(t, ctx.Require(CardInserted))
+	require.ErrorIs(t, ctx.Require(Authenticated), errorcode.ErrPinNumberNotValidated)
+
+	_ = ctx.SetPinNumValid()
+	require.ErrorIs(t, ctx.Require(AccountSelected), errorcode.ErrNoAccountSelected)
+
+	_ = ctx.SetAccountID("test_account_1")
+	require.NoError(t, ctx.Require(AccountSelected))
+
+	_ = ctx.BeginTransaction()
+	require.ErrorIs(t, ctx.Require(AccountSelected), errorcode.ErrTransactionInProgress)
+
+	_ = ctx.BeginEject()
+	require.ErrorIs(t, ctx.Require(CardInserted), errorcode.ErrCardEjecting)
+}
+
+func TestClear(t *testing.T) {
+	ctx := NewAtmContext()
+	_ = ctx.SetCard(model.Card{HolderName: "test user", Number: "4111111111111111"})
+	_ = ctx.SetPinNumValid()
+
+	ctx.Clear()
+	require.Equal(t, Idle, ctx.State())
+	require.Nil(t, ctx.ViewCard())
+}
+
+func TestSessionDoesNotPrintPan(t *testing.T) {
+	ctx := NewAtmContext()
+	_ = ctx.SetCard(model.Card{HolderName: "test user", Number: "4111111111111111"})
+
+	require.NotContains(t, fmt.Sprintf("%+v", ctx), "4111111111111111")
+	require.NotContains(t, fmt.Sprintf("%+v", *ctx.ViewCard()), "4111111111111111")
+	require.NotContains(t, fmt.Sprintf("%#v", ctx.ViewCard()), "4111111111111111")
+}
@@ -0,0 +1,55 @@
+package context
+
+import "fmt"
+
+// State is the stage a session has reached. A session moves through the
+// states in order and may only change state along a declared transition.
+type State int
+
+const (
+	Idle State = iota
+	CardInserted
+	Authenticated
+	AccountSelected
+	InTransaction
+	Ejecting
+)
+
+var stateNames = map[State]string{
+	Idle:            "idle",
+	CardInserted:    "card_inserted",
+	Authenticated:   "authenticated",
+	AccountSelected: "account_selected",
+	InTransaction:   "in_transaction",
+	Ejecting:        "ejecting",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// transitions lists, for every state, the states it may move to.
+// CardInserted -> Idle is the card being retained.
+var transitions = map[State][]State{
+	Idle:            {CardInserted},
+	CardInserted:    {Authenticated, Ejecting, Idle},
+	Authenticated:   {AccountSelected, Ejecting},
+	AccountSelected: {AccountSelected, InTransaction, Ejecting},
+	InTransaction:   {AccountSelected, Ejecting},
+	Ejecting:        {Idle},
+}
+
+// CanTransition reports whether a session in state from may move to state to.
+func CanTransition(from, to State) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
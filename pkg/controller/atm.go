@@ -1,116 +1,301 @@
 package controller
 
 import (
-	"atm/pkg/context"
+	"atm/pkg/amountpolicy"
+	"atm/pkg/clock"
+	atmcontext "atm/pkg/context"
+	"atm/pkg/dispenser"
+	"atm/pkg/emv"
 	"atm/pkg/errorcode"
+	"atm/pkg/fees"
+	"atm/pkg/holds"
+	"atm/pkg/journal"
+	"atm/pkg/limits"
 	"atm/pkg/model"
+	"atm/pkg/overdraft"
+	"atm/pkg/pinblock"
+	"atm/pkg/pinpolicy"
 	"atm/pkg/service"
+	"atm/pkg/velocity"
+	"context"
 	"errors"
-	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 )
 
+// AtmController drives one customer session. It is safe for concurrent use:
+// operations are serialised, and an operation started while another is in
+// flight fails with errorcode.ErrOperationInProgress instead of waiting.
 type AtmController struct {
-	ctx        *context.AtmContext
-	accountSvc service.AccountInterface
-	cardSvc    service.CardInterface
+	mu sync.Mutex
+
+	terminalID string
+	session    *atmcontext.AtmContext
+	// chipData is the ARQC data of the card in the session, if read from
+	// its chip.
+	chipData   []byte
+	accountSvc service.AccountInterfaceV2
+	cardSvc    service.CardInterfaceV2
+	pinCipher  *pinblock.Cipher
+	pinPolicy  pinpolicy.Policy
+	amounts    amountpolicy.Policy
+	fees       *fees.Schedule
+	holds      holds.Policy
+	feeQuote   *feeQuote
+	limits     *limits.Engine
+	overdraft  overdraft.Policies
+	velocity   *velocity.Checker
+	dispenser  *dispenser.Dispenser
+	cashSvc    service.DispenserInterface
+	acceptor   service.AcceptorInterface
+	escrow     *escrow
+	journal    journal.Journal
+	clock      clock.Clock
+	logger     *slog.Logger
+
+	maxPinAttempts     int
+	maxCardPinAttempts int
+	pinAttempts        *PinAttempts
+
+	idleTimeout  time.Duration
+	ejectTimeout time.Duration
 }
 
-type Options struct {
-	accountSvc service.AccountInterface
-	cardSvc    service.CardInterface
-}
+func NewAtmController(opts Options) (*AtmController, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	logger := opts.Logger
+	if opts.TerminalID != "" {
+		logger = logger.With("terminal", opts.TerminalID)
+	}
 
-func NewAtmController(opts Options) *AtmController {
 	return &AtmController{
-		ctx:        context.NewAtmContext(),
-		accountSvc: opts.accountSvc,
-		cardSvc:    opts.cardSvc,
+		terminalID: opts.TerminalID,
+		session:    atmcontext.NewAtmContext(),
+		accountSvc: opts.AccountSvc,
+		cardSvc:    opts.CardSvc,
+		pinCipher:  opts.PinCipher,
+		pinPolicy:  *opts.PinPolicy,
+		amounts:    opts.AmountPolicy,
+		fees:       opts.Fees,
+		holds:      opts.Holds,
+		limits:     opts.Limits,
+		overdraft:  opts.Overdraft,
+		velocity:   opts.Velocity,
+		dispenser:  opts.Dispenser,
+		cashSvc:    opts.CashSvc,
+		acceptor:   opts.AcceptorSvc,
+		journal:    opts.Journal,
+		clock:      opts.Clock,
+		logger:     logger,
+
+		maxPinAttempts:     opts.MaxPinAttempts,
+		maxCardPinAttempts: opts.MaxCardPinAttempts,
+		pinAttempts:        opts.PinAttempts,
+
+		idleTimeout:  opts.IdleTimeout,
+		ejectTimeout: opts.EjectTimeout,
+	}, nil
+}
+
+// TerminalID returns the terminal this controller was created for.
+func (ctrl *AtmController) TerminalID() string {
+	return ctrl.terminalID
+}
+
+// State returns the current state of the controller's session.
+func (ctrl *AtmController) State() atmcontext.State {
+	return ctrl.session.State()
+}
+
+func (ctrl *AtmController) InsertCard(ctx context.Context, card model.Card) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	return ctrl.insertCard(ctx, card, nil)
+}
+
+// InsertChipCard is like InsertCard for a card read from its chip. The
+// chip's ARQC data is passed to the account service with the PIN.
+func (ctrl *AtmController) InsertChipCard(ctx context.Context, data emv.CardData) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
 	}
+	defer ctrl.mu.Unlock()
+
+	return ctrl.insertCard(ctx, data.Card, data.ChipData)
 }
 
-func (ctrl *AtmController) InsertCard(card model.Card) error {
-	err := ctrl.cardSvc.InsertCard(card)
+func (ctrl *AtmController) insertCard(ctx context.Context, card model.Card, chipData []byte) error {
+	if ctrl.session.State() != atmcontext.Idle {
+		return errorcode.Wrap(errorcode.InsertCardFail, ctrl.session.Transition(atmcontext.CardInserted))
+	}
+	if err := card.Validate(ctrl.clock.Now()); err != nil {
+		return err
+	}
+	ctrl.feeQuote = nil
+
+	err := ctrl.cardSvc.InsertCard(ctx, card)
 	if err != nil {
-		fmt.Errorf("%s: %w", errorcode.InsertCardFail, err)
-		return errors.New(errorcode.InsertCardFail)
+		ctrl.logger.Warn("card insert failed", "card", card, "err", err)
+		return errorcode.Wrap(errorcode.InsertCardFail, err)
 	}
 
-	ctrl.ctx.SetCard(card)
+	if err := ctrl.session.SetCard(card); err != nil {
+		return err
+	}
+	ctrl.chipData = chipData
+	ctrl.touch()
+	ctrl.logger.Info("card inserted", "card", card)
 
 	return nil
 }
 
-func (ctrl *AtmController) RemoveCard() error {
-	if !ctrl.ctx.HasCardInserted() {
-		return errors.New(errorcode.NoCardFound)
+func (ctrl *AtmController) RemoveCard(ctx context.Context) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
 	}
+	defer ctrl.mu.Unlock()
 
-	err := ctrl.cardSvc.RemoveCard()
-	if err != nil {
-		fmt.Errorf("%s: %w", errorcode.RemoveCardFail, err)
-		return errors.New(errorcode.RemoveCardFail)
+	if !ctrl.session.HasCardInserted() {
+		return errorcode.ErrNoCardFound
+	}
+	ctrl.abandonEscrow(ctx)
+	if err := ctrl.session.BeginEject(); err != nil {
+		return err
 	}
 
-	ctrl.ctx.Clear()
+	err := ctrl.cardSvc.RemoveCard(ctx)
+	if err != nil {
+		ctrl.logger.Warn("card removal failed", "err", err)
+		return errorcode.Wrap(errorcode.RemoveCardFail, err)
+	}
 
-	return nil
+	return ctrl.session.CompleteEject()
 }
 
-func (ctrl *AtmController) EnterPin(pinNumber string) error {
-	if !ctrl.ctx.HasCardInserted() {
-		return errors.New(errorcode.NoCardFound)
+// EnterPin verifies pinNumber for the inserted card. A wrong PIN leaves the
+// card in the session so the customer can try again; once the session or
+// per-card attempt limit is reached the card is retained and the session ends.
+// If the card cannot be retained, the session stays in Ejecting until
+// RemoveCard hands it back.
+func (ctrl *AtmController) EnterPin(ctx context.Context, pinNumber string) (PinResult, error) {
+	if !ctrl.mu.TryLock() {
+		return PinResult{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.session.Require(atmcontext.CardInserted); err != nil {
+		return PinResult{}, err
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return errors.New(errorcode.NoCardFound)
+	ctrl.touch()
+	if ctrl.session.State() != atmcontext.CardInserted {
+		return PinResult{}, ctrl.session.Transition(atmcontext.Authenticated)
 	}
+	card := *ctrl.session.ViewCard()
 
-	isValid, err := ctrl.accountSvc.EnterPinNumber(*card, pinNumber)
+	if ctrl.triesRemaining(card) <= 0 {
+		return ctrl.retainCard(ctx)
+	}
+	if err := ctrl.checkVelocity(velocity.PinEntry, "", model.Money{}); err != nil {
+		return PinResult{TriesRemaining: ctrl.triesRemaining(card)}, err
+	}
+
+	block, err := ctrl.pinCipher.Encrypt(pinNumber, card.Number)
 	if err != nil {
-		ctrl.ctx.SetPinNumValid(false)
-		fmt.Errorf("%s: %w", errorcode.PinNumberCheckFail, err)
-		return errors.New(errorcode.PinNumberCheckFail)
+		return PinResult{TriesRemaining: ctrl.triesRemaining(card)}, err
+	}
+
+	isValid, err := ctrl.accountSvc.VerifyPin(ctx, service.EncryptedPin{
+		PAN:      card.Number,
+		Format:   ctrl.pinCipher.Format(),
+		PinBlock: block,
+		ChipData: ctrl.chipData,
+	})
+	if err != nil {
+		return PinResult{TriesRemaining: ctrl.triesRemaining(card)}, errorcode.WrapRetryable(errorcode.PinNumberCheckFail, err)
 	}
 	if !isValid {
-		ctrl.ctx.Clear()
-		return errors.New(errorcode.InvalidPinNumber)
+		ctrl.session.FailPinAttempt()
+		ctrl.pinAttempts.Fail(card.Number)
+
+		remaining := ctrl.triesRemaining(card)
+		if remaining <= 0 {
+			return ctrl.retainCard(ctx)
+		}
+
+		return PinResult{TriesRemaining: remaining}, errorcode.ErrInvalidPinNumber
 	}
 
-	ctrl.ctx.SetPinNumValid(true)
+	ctrl.pinAttempts.Reset(card.Number)
 
-	return nil
+	return PinResult{TriesRemaining: ctrl.maxPinAttempts}, ctrl.session.SetPinNumValid()
 }
 
-func (ctrl *AtmController) GetAccountIDs() ([]string, error) {
-	if !ctrl.ctx.HasCardInserted() {
-		return nil, errors.New(errorcode.NoCardFound)
+func (ctrl *AtmController) triesRemaining(card model.Card) int {
+	remaining := ctrl.maxPinAttempts - ctrl.session.PinAttempts()
+	if ctrl.maxCardPinAttempts > 0 {
+		remaining = min(remaining, ctrl.maxCardPinAttempts-ctrl.pinAttempts.Failures(card.Number))
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return nil, errors.New(errorcode.NoCardFound)
-	}
-	if !ctrl.ctx.IsPinNumValidated() {
-		return nil, errors.New(errorcode.PinNumberNotValidated)
+
+	return max(remaining, 0)
+}
+
+func (ctrl *AtmController) retainCard(ctx context.Context) (PinResult, error) {
+	result := PinResult{CardRetained: true}
+
+	if err := ctrl.cardSvc.RetainCard(ctx); err != nil {
+		// The card may still be in the reader, so the session must not go
+		// back to Idle where a new card could be inserted. It waits in
+		// Ejecting for RemoveCard to hand the card back.
+		ctrl.logger.Error("card retention failed", "err", err)
+		_ = ctrl.session.BeginEject()
+		return result, errorcode.Wrap(errorcode.RetainCardFail, err)
 	}
 
-	return ctrl.accountSvc.GetAccountIDs()
+	ctrl.session.Retain()
+
+	return result, errorcode.ErrCardRetained
 }
 
-func (ctrl *AtmController) SelectAccount(accountID string) error {
-	if !ctrl.ctx.HasCardInserted() {
-		return errors.New(errorcode.NoCardFound)
+func (ctrl *AtmController) GetAccountIDs(ctx context.Context) ([]string, error) {
+	if !ctrl.mu.TryLock() {
+		return nil, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.session.Require(atmcontext.Authenticated); err != nil {
+		return nil, err
+	}
+	ctrl.touch()
+
+	accountIDs, err := ctrl.accountSvc.GetAccountIDs(ctx)
+	if err != nil {
+		return nil, errorcode.WrapRetryable(errorcode.GetAccountIDsFail, err)
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return errors.New(errorcode.NoCardFound)
+
+	return accountIDs, nil
+}
+
+func (ctrl *AtmController) SelectAccount(ctx context.Context, accountID string) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
 	}
-	if !ctrl.ctx.IsPinNumValidated() {
-		return errors.New(errorcode.PinNumberNotValidated)
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.session.Require(atmcontext.Authenticated); err != nil {
+		return err
 	}
+	ctrl.touch()
 
-	accountIDs, err := ctrl.accountSvc.GetAccountIDs()
+	accountIDs, err := ctrl.accountSvc.GetAccountIDs(ctx)
 	if err != nil {
-		return errors.New(errorcode.GetAccountIDsFail)
+		return errorcode.WrapRetryable(errorcode.GetAccountIDsFail, err)
 	}
 
 	var matchFound bool
@@ -122,102 +307,225 @@ func (ctrl *AtmController) SelectAccount(accountID string) error {
 	}
 
 	if !matchFound {
-		return errors.New(errorcode.NoMatchingAccountID)
+		return errorcode.ErrNoMatchingAccountID
 	}
 
-	err = ctrl.accountSvc.SelectAccountID(accountID)
+	err = ctrl.accountSvc.SelectAccountID(ctx, accountID)
 	if err != nil {
-		return errors.New(errorcode.FailedToSelectAccountID)
+		return errorcode.WrapRetryable(errorcode.FailedToSelectAccountID, err)
 	}
 
-	ctrl.ctx.SetAccountID(accountID)
-
-	return nil
+	return ctrl.session.SetAccountID(accountID)
 }
 
-func (ctrl *AtmController) GetBalance(accountID string) (int, error) {
-	if !ctrl.ctx.HasCardInserted() {
-		return -1, errors.New(errorcode.NoCardFound)
+// requireAccount checks that accountID is the selected account and moves the
+// session into InTransaction. The caller must call ctrl.session.EndTransaction
+// once the transaction is finished.
+func (ctrl *AtmController) requireAccount(accountID string) error {
+	if err := ctrl.session.Require(atmcontext.AccountSelected); err != nil {
+		return err
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return -1, errors.New(errorcode.NoCardFound)
+	if ctrl.session.GetAccountID() != accountID {
+		return errorcode.ErrAccountIDMismatch
 	}
-	if !ctrl.ctx.IsPinNumValidated() {
-		return -1, errors.New(errorcode.PinNumberNotValidated)
+	ctrl.touch()
+
+	return ctrl.session.BeginTransaction()
+}
+
+// GetBalance returns the ledger balance of accountID. GetBalances also
+// reports the available balance and the holds on the account.
+func (ctrl *AtmController) GetBalance(ctx context.Context, accountID string) (model.Money, error) {
+	balances, err := ctrl.GetBalances(ctx, accountID)
+	if err != nil {
+		return model.Money{}, err
 	}
-	if ctrl.ctx.GetAccountID() == "" {
-		return -1, errors.New(errorcode.NoAccountSelected)
+
+	return balances.Ledger, nil
+}
+
+func (ctrl *AtmController) GetBalances(ctx context.Context, accountID string) (service.Balances, error) {
+	if !ctrl.mu.TryLock() {
+		return service.Balances{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.requireAccount(accountID); err != nil {
+		return service.Balances{}, err
 	}
-	if ctrl.ctx.GetAccountID() != accountID {
-		return -1, errors.New(errorcode.AccountIDMismatch)
+	defer ctrl.session.EndTransaction()
+
+	fee, err := ctrl.requireFee(fees.BalanceInquiry, accountID, model.Money{})
+	if err != nil {
+		return service.Balances{}, err
 	}
 
-	balance, err := ctrl.accountSvc.GetBalance(accountID)
+	balances, err := ctrl.accountSvc.GetBalances(ctx, accountID)
 	if err != nil {
-		return -1, errors.New(errorcode.FailedToGetBalance)
+		return service.Balances{}, errorcode.WrapRetryable(errorcode.FailedToGetBalance, err)
 	}
 
-	return balance, nil
+	// The fee is only charged once there is a balance to show, which is
+	// then adjusted for it.
+	if fee.IsPositive() {
+		ledger, err := ctrl.chargeFee(ctx, fees.BalanceInquiry, accountID, fee)
+		if err != nil {
+			return service.Balances{}, err
+		}
+		available, err := balances.Available.Sub(fee)
+		if err != nil {
+			return service.Balances{}, err
+		}
+		balances.Ledger, balances.Available = ledger, available
+	}
+
+	return balances, nil
 }
 
-func (ctrl *AtmController) MakeDeposit(accountID string, amount int) (int, error) {
-	if !ctrl.ctx.HasCardInserted() {
-		return -1, errors.New(errorcode.NoCardFound)
+func (ctrl *AtmController) MakeDeposit(ctx context.Context, accountID string, amount model.Money) (model.Money, error) {
+	if !ctrl.mu.TryLock() {
+		return model.Money{}, errorcode.ErrOperationInProgress
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return -1, errors.New(errorcode.NoCardFound)
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.requireAccount(accountID); err != nil {
+		return model.Money{}, err
 	}
-	if !ctrl.ctx.IsPinNumValidated() {
-		return -1, errors.New(errorcode.PinNumberNotValidated)
+	defer ctrl.session.EndTransaction()
+
+	if err := ctrl.amounts.ValidateDeposit(amount); err != nil {
+		return model.Money{}, err
 	}
-	if ctrl.ctx.GetAccountID() == "" {
-		return -1, errors.New(errorcode.NoAccountSelected)
+	if err := ctrl.checkVelocity(velocity.Deposit, accountID, amount); err != nil {
+		return model.Money{}, err
 	}
-	if ctrl.ctx.GetAccountID() != accountID {
-		return -1, errors.New(errorcode.AccountIDMismatch)
+
+	held, err := ctrl.depositHolds(amount)
+	if err != nil {
+		return model.Money{}, err
 	}
 
-	newBalance, err := ctrl.accountSvc.MakeDeposit(accountID, amount)
+	newBalance, err := ctrl.accountSvc.DepositWithHolds(ctx, accountID, amount, held)
 	if err != nil {
-		return -1, errors.New(errorcode.FailedToMakeDeposit)
+		return model.Money{}, errorcode.WrapRetryable(errorcode.FailedToMakeDeposit, err)
 	}
 
 	return newBalance, nil
 }
 
-func (ctrl *AtmController) MakeWithdrawl(accountID string, withdrawAmt int) (int, error) {
-	if !ctrl.ctx.HasCardInserted() {
-		return -1, errors.New(errorcode.NoCardFound)
+func (ctrl *AtmController) MakeWithdrawl(ctx context.Context, accountID string, withdrawAmt model.Money) (model.Money, error) {
+	return ctrl.MakeWithdrawlWithPreference(ctx, accountID, withdrawAmt, dispenser.Preference{})
+}
+
+// MakeWithdrawlWithPreference is like MakeWithdrawl but dispenses the notes
+// the customer asked for where the cassettes allow it.
+func (ctrl *AtmController) MakeWithdrawlWithPreference(ctx context.Context, accountID string, withdrawAmt model.Money, pref dispenser.Preference) (model.Money, error) {
+	if !ctrl.mu.TryLock() {
+		return model.Money{}, errorcode.ErrOperationInProgress
 	}
-	card := ctrl.ctx.ViewCard()
-	if card == nil {
-		return -1, errors.New(errorcode.NoCardFound)
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.requireAccount(accountID); err != nil {
+		return model.Money{}, err
 	}
-	if !ctrl.ctx.IsPinNumValidated() {
-		return -1, errors.New(errorcode.PinNumberNotValidated)
+	defer ctrl.session.EndTransaction()
+
+	if err := ctrl.amounts.ValidateWithdrawal(withdrawAmt); err != nil {
+		return model.Money{}, err
 	}
-	if ctrl.ctx.GetAccountID() == "" {
-		return -1, errors.New(errorcode.NoAccountSelected)
+
+	var mix dispenser.Mix
+	if ctrl.dispenser != nil {
+		var err error
+		mix, err = ctrl.dispenser.Mix(withdrawAmt, pref)
+		if err != nil {
+			return model.Money{}, err
+		}
+	}
+
+	fee, err := ctrl.requireFee(fees.Withdrawal, accountID, withdrawAmt)
+	if err != nil {
+		return model.Money{}, err
 	}
-	if ctrl.ctx.GetAccountID() != accountID {
-		return -1, errors.New(errorcode.AccountIDMismatch)
+	if err := ctrl.checkVelocity(velocity.Withdrawal, accountID, withdrawAmt); err != nil {
+		return model.Money{}, err
+	}
+	release, err := ctrl.reserveWithdrawal(accountID, withdrawAmt)
+	if err != nil {
+		return model.Money{}, err
 	}
 
-	currentBalance, err := ctrl.accountSvc.GetBalance(accountID)
+	newBalance, err := ctrl.accountSvc.DebitWithCheck(ctx, accountID, service.Debit{
+		Amount: withdrawAmt,
+		Fee:    fee,
+		Policy: ctrl.overdraft.For(accountID),
+	})
 	if err != nil {
-		return -1, errors.New(errorcode.FailedToGetBalance)
+		// An abandoned call may still post the debit, so the limits stay
+		// reserved and a retry, which could debit twice, is not offered.
+		if ctx.Err() != nil {
+			ctrl.record(journal.Entry{Event: journal.DebitUnknown, AccountID: accountID, Amount: withdrawAmt, Err: err.Error()})
+			return model.Money{}, errorcode.Wrap(errorcode.DebitUnknown, err)
+		}
+		release(withdrawAmt)
+		if errors.Is(err, errorcode.ErrIsOverdraw) {
+			return model.Money{}, errorcode.ErrIsOverdraw
+		}
+		return model.Money{}, errorcode.WrapRetryable(errorcode.FailedToWithdraw, err)
 	}
 
-	if currentBalance < withdrawAmt {
-		return -1, errors.New(errorcode.IsOverdraw)
+	ctrl.record(journal.Entry{Event: journal.Debited, AccountID: accountID, Amount: withdrawAmt})
+	if ctrl.dispenser != nil {
+		if newBalance, err = ctrl.deliverCash(ctx, accountID, withdrawAmt, mix, newBalance, release); err != nil {
+			return model.Money{}, err
+		}
 	}
 
-	newBalance, err := ctrl.accountSvc.Withdraw(accountID, withdrawAmt)
-	if err != nil {
-		return -1, errors.New(errorcode.FailedToWithdraw)
+	// The cash has been delivered, so a failed fee is left to the journal
+	// rather than failing the withdrawal.
+	if fee.IsPositive() {
+		if balance, err := ctrl.chargeFee(context.WithoutCancel(ctx), fees.Withdrawal, accountID, fee); err == nil {
+			newBalance = balance
+		}
 	}
 
 	return newBalance, nil
 }
+
+// reserveWithdrawal counts amount against the card and account limits
+// before the account is debited. The returned func gives back part or all
+// of it if the withdrawal is declined or reversed.
+func (ctrl *AtmController) reserveWithdrawal(accountID string, amount model.Money) (func(model.Money), error) {
+	if ctrl.limits == nil {
+		return func(model.Money) {}, nil
+	}
+
+	return ctrl.limits.Reserve(*ctrl.session.ViewCard(), accountID, amount, ctrl.clock.Now())
+}
+
+// checkVelocity asks the velocity checker whether op may go ahead for the
+// session's card on accountID and, if so, counts the attempt. It is called
+// before the operation runs, so attempts that then fail count too.
+func (ctrl *AtmController) checkVelocity(op velocity.Operation, accountID string, amount model.Money) error {
+	if ctrl.velocity == nil {
+		return nil
+	}
+
+	err := ctrl.velocity.CheckAndRecord(ctrl.velocityAttempt(op, accountID, amount))
+	if err != nil {
+		ctrl.logger.Warn("transaction declined by velocity check", "operation", op, "err", err)
+	}
+
+	return err
+}
+
+func (ctrl *AtmController) velocityAttempt(op velocity.Operation, accountID string, amount model.Money) velocity.Attempt {
+	return velocity.Attempt{
+		Operation:  op,
+		Card:       *ctrl.session.ViewCard(),
+		AccountID:  accountID,
+		TerminalID: ctrl.terminalID,
+		Amount:     amount,
+		Time:       ctrl.clock.Now(),
+	}
+}
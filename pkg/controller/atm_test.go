@@ -1,151 +1,414 @@
 package controller
 
 import (
+	"atm/pkg/amountpolicy"
+	atmcontext "atm/pkg/context"
+	"atm/pkg/dispenser"
+	"atm/pkg/emv"
 	"atm/pkg/errorcode"
 	"atm/pkg/internal/testutil"
 	"atm/pkg/model"
+	"atm/pkg/service"
+	"context"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
+func newTestController(t *testing.T, opts Options) *AtmController {
+	t.Helper()
+
+	if opts.PinCipher == nil {
+		opts.PinCipher = testutil.NewPinCipher()
+	}
+	if opts.AccountSvc == nil {
+		opts.AccountSvc = service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions())
+	}
+	ctrl, err := NewAtmController(opts)
+	require.NoError(t, err)
+
+	return ctrl
+}
+
+// newSelectedController returns a controller built from testOptions with
+// a card inserted, its PIN entered and the first of acctOpts.AccountIDs
+// selected.
+func newSelectedController(t *testing.T, acctOpts testutil.DummyAcctTestOptions, opts Options) *AtmController {
+	t.Helper()
+
+	if len(acctOpts.AccountIDs) == 0 {
+		acctOpts.AccountIDs = []string{"test_account_1"}
+	}
+	ctrl := newTestController(t, testOptions(acctOpts, opts))
+	selectAccount(t, ctrl, "4111111111111111", acctOpts.AccountIDs[0])
+
+	return ctrl
+}
+
+// testOptions fills in the dummy card service, a dummy account service
+// built from acctOpts and the test PIN cipher where opts leaves them unset.
+// AccountIDs defaults to test_account_1.
+func testOptions(acctOpts testutil.DummyAcctTestOptions, opts Options) Options {
+	if len(acctOpts.AccountIDs) == 0 {
+		acctOpts.AccountIDs = []string{"test_account_1"}
+	}
+	if opts.CardSvc == nil {
+		opts.CardSvc = service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}))
+	}
+	if opts.AccountSvc == nil {
+		opts.AccountSvc = service.AdaptAccount(testutil.NewDummyAccountSvc(acctOpts), testutil.NewAccountAdapterOptions())
+	}
+	if opts.PinCipher == nil {
+		opts.PinCipher = testutil.NewPinCipher()
+	}
+
+	return opts
+}
+
+// selectAccount inserts the card numbered number, enters its PIN and
+// selects accountID.
+func selectAccount(t *testing.T, ctrl *AtmController, number, accountID string) {
+	t.Helper()
+
+	require.NoError(t, ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     number,
+	}))
+	_, err := ctrl.EnterPin(context.Background(), "1234")
+	require.NoError(t, err)
+	require.NoError(t, ctrl.SelectAccount(context.Background(), accountID))
+}
+
+func usd(amount int) model.Money {
+	return model.NewMoney(int64(amount), testutil.Currency)
+}
+
+func TestNewAtmControllerMissingServices(t *testing.T) {
+	_, err := NewAtmController(Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+	})
+	require.ErrorIs(t, err, errorcode.ErrNilAccountService)
+
+	_, err = NewAtmController(Options{
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions()),
+	})
+	require.ErrorIs(t, err, errorcode.ErrNilCardService)
+
+	_, err = NewAtmController(Options{
+		CardSvc:    service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions()),
+	})
+	require.ErrorIs(t, err, errorcode.ErrNilPinCipher)
+}
+
 func TestInsertCard(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
-	require.True(t, ctrl.ctx.HasCardInserted())
+	require.True(t, ctrl.session.HasCardInserted())
 
-	err = ctrl.RemoveCard()
+	err = ctrl.RemoveCard(context.Background())
 	require.NoError(t, err)
-	require.False(t, ctrl.ctx.HasCardInserted())
+	require.False(t, ctrl.session.HasCardInserted())
 }
 
 func TestInsertCardError(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{
 			ErrOnInsert: true,
-		}),
+		})),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.Error(t, err)
-	require.False(t, ctrl.ctx.HasCardInserted())
+	require.False(t, ctrl.session.HasCardInserted())
 
-	err = ctrl.RemoveCard()
+	err = ctrl.RemoveCard(context.Background())
 	require.Error(t, err)
-	require.False(t, ctrl.ctx.HasCardInserted())
+	require.False(t, ctrl.session.HasCardInserted())
+}
+
+func TestInsertCardInvalid(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		Clock:   testutil.NewFakeClock(time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)),
+	})
+
+	err := ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111112",
+	})
+	require.ErrorIs(t, err, errorcode.ErrCardLuhnCheckFail)
+	require.False(t, ctrl.session.HasCardInserted())
+
+	err = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+		Expiry:     "2405",
+	})
+	require.ErrorIs(t, err, errorcode.ErrCardExpired)
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+
+	err = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+		Expiry:     "2406",
+	})
+	require.NoError(t, err)
 }
 
 func TestCardRemove(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
-	require.True(t, ctrl.ctx.HasCardInserted())
+	require.True(t, ctrl.session.HasCardInserted())
 
-	err = ctrl.RemoveCard()
+	err = ctrl.RemoveCard(context.Background())
 	require.NoError(t, err)
-	require.False(t, ctrl.ctx.HasCardInserted())
+	require.False(t, ctrl.session.HasCardInserted())
 }
 
 func TestCardRemoveError(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{
 			ErrOnRemove: true,
-		}),
+		})),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
-	require.True(t, ctrl.ctx.HasCardInserted())
+	require.True(t, ctrl.session.HasCardInserted())
 
-	err = ctrl.RemoveCard()
+	err = ctrl.RemoveCard(context.Background())
 	require.Error(t, err)
-	require.True(t, ctrl.ctx.HasCardInserted())
+	require.True(t, ctrl.session.HasCardInserted())
 }
 
 func TestPinNumber(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc:    testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}),
+	ctrl := newTestController(t, Options{
+		CardSvc:    service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions()),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
 
-	err = ctrl.EnterPin("123123231")
+	_, err = ctrl.EnterPin(context.Background(), "123123231")
 	require.NoError(t, err)
-	require.True(t, ctrl.ctx.IsPinNumValidated())
+	require.True(t, ctrl.session.IsPinNumValidated())
 }
 
 func TestPinNumberSvcError(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			ErrOnPinNumberEnter: true,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
 
-	err = ctrl.EnterPin("123123231")
+	_, err = ctrl.EnterPin(context.Background(), "123123231")
 	require.Error(t, err)
-	require.False(t, ctrl.ctx.IsPinNumValidated())
+	require.False(t, ctrl.session.IsPinNumValidated())
 }
 
 func TestPinNumberInvalidError(t *testing.T) {
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			InvalidPinNumberEnter: true,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	err := ctrl.InsertCard(model.Card{
+	err := ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 	require.NoError(t, err)
 
-	err = ctrl.EnterPin("123123231")
+	result, err := ctrl.EnterPin(context.Background(), "123123231")
 	require.Error(t, err)
 	require.EqualError(t, err, errorcode.InvalidPinNumber)
-	require.False(t, ctrl.ctx.IsPinNumValidated())
+	require.False(t, ctrl.session.IsPinNumValidated())
+	require.True(t, ctrl.session.HasCardInserted())
+	require.Equal(t, DefaultMaxPinAttempts-1, result.TriesRemaining)
+	require.False(t, result.CardRetained)
+}
+
+func TestPinNumberEncrypted(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			ValidPinNumber: "4321",
+		}), testutil.NewAccountAdapterOptions()),
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+
+	_, err := ctrl.EnterPin(context.Background(), "1234")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinNumber)
+
+	_, err = ctrl.EnterPin(context.Background(), "12")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinFormat)
+
+	_, err = ctrl.EnterPin(context.Background(), "4321")
+	require.NoError(t, err)
+	require.True(t, ctrl.session.IsPinNumValidated())
+}
+
+type capturingAcctSvc struct {
+	service.AccountInterfaceV2
+	verified []service.EncryptedPin
+}
+
+func (c *capturingAcctSvc) VerifyPin(ctx context.Context, req service.EncryptedPin) (bool, error) {
+	c.verified = append(c.verified, req)
+	return c.AccountInterfaceV2.VerifyPin(ctx, req)
+}
+
+func TestPinNumberCarriesChipData(t *testing.T) {
+	acctSvc := &capturingAcctSvc{
+		AccountInterfaceV2: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions()),
+	}
+	ctrl := newTestController(t, Options{
+		CardSvc:    service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: acctSvc,
+	})
+	_ = ctrl.InsertChipCard(context.Background(), emv.CardData{
+		Card: model.Card{
+			HolderName: "test user",
+			Number:     "4111111111111111",
+		},
+		ChipData: []byte{0x9F, 0x36, 0x02, 0x00, 0x42},
+	})
+
+	_, err := ctrl.EnterPin(context.Background(), "4321")
+	require.NoError(t, err)
+	require.Len(t, acctSvc.verified, 1)
+	require.Equal(t, "4111111111111111", acctSvc.verified[0].PAN)
+	require.Equal(t, []byte{0x9F, 0x36, 0x02, 0x00, 0x42}, acctSvc.verified[0].ChipData)
+	require.NotContains(t, string(acctSvc.verified[0].PinBlock), "4321")
+}
+
+func TestPinNumberRetainCard(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			InvalidPinNumberEnter: true,
+		}), testutil.NewAccountAdapterOptions()),
+		MaxPinAttempts: 2,
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+
+	result, err := ctrl.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinNumber)
+	require.Equal(t, 1, result.TriesRemaining)
+
+	result, err = ctrl.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrCardRetained)
+	require.True(t, result.CardRetained)
+	require.Equal(t, 0, result.TriesRemaining)
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+	require.False(t, ctrl.session.HasCardInserted())
+}
+
+func TestPinNumberRetainCardAcrossSessions(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			InvalidPinNumberEnter: true,
+		}), testutil.NewAccountAdapterOptions()),
+		MaxCardPinAttempts: 2,
+	})
+	card := model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	}
+
+	_ = ctrl.InsertCard(context.Background(), card)
+	result, err := ctrl.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinNumber)
+	require.Equal(t, 1, result.TriesRemaining)
+	require.NoError(t, ctrl.RemoveCard(context.Background()))
+
+	_ = ctrl.InsertCard(context.Background(), card)
+	result, err = ctrl.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrCardRetained)
+	require.True(t, result.CardRetained)
+}
+
+func TestPinNumberRetainCardError(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{
+			ErrOnRetain: true,
+		})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			InvalidPinNumberEnter: true,
+		}), testutil.NewAccountAdapterOptions()),
+		MaxPinAttempts: 1,
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+
+	result, err := ctrl.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrRetainCardFail)
+	require.True(t, result.CardRetained)
+	require.Equal(t, atmcontext.Ejecting, ctrl.State())
+	require.True(t, ctrl.session.HasCardInserted())
+
+	err = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+	require.ErrorIs(t, err, errorcode.ErrIllegalTransition)
+
+	require.NoError(t, ctrl.RemoveCard(context.Background()))
+	require.Equal(t, atmcontext.Idle, ctrl.State())
 }
 
 func TestGetAccountIDs(t *testing.T) {
 	expectedAccountIDs := []string{"test_account_1"}
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs: expectedAccountIDs,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	accountIDs, err := ctrl.GetAccountIDs()
+	accountIDs, err := ctrl.GetAccountIDs(context.Background())
 	require.NoError(t, err)
 	require.EqualValues(t, accountIDs, expectedAccountIDs)
 }
@@ -153,22 +416,23 @@ func TestGetAccountIDs(t *testing.T) {
 func TestGetAccountIDsError(t *testing.T) {
 	expectedAccountIDs := []string{"test_account_1"}
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			ErrOnGetAccountIDs: true,
 			AccountIDs:         expectedAccountIDs,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	accountIDs, err := ctrl.GetAccountIDs()
-	require.Error(t, err)
+	accountIDs, err := ctrl.GetAccountIDs(context.Background())
+	require.ErrorIs(t, err, errorcode.ErrGetAccountIDsFail)
+	require.True(t, errorcode.IsRetryable(err))
 	require.Empty(t, accountIDs)
 }
 
@@ -176,20 +440,20 @@ func TestSelectAccountID(t *testing.T) {
 	selectedAccountID := "test_account_1"
 	expectedAccountIDs := []string{selectedAccountID}
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs: expectedAccountIDs,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 }
 
@@ -197,43 +461,45 @@ func TestSelectAccountIDInternalError(t *testing.T) {
 	selectedAccountID := "test_account_1"
 	expectedAccountIDs := []string{selectedAccountID}
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			ErrOnSelectAccountID: true,
 			AccountIDs:           expectedAccountIDs,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.Error(t, err)
-	require.EqualError(t, err, errorcode.FailedToSelectAccountID)
+	require.ErrorIs(t, err, errorcode.ErrFailedToSelectAccountID)
+	require.ErrorContains(t, err, "failed to select accountID")
+	require.True(t, errorcode.IsRetryable(err))
 }
 
 func TestSelectAccountID_DNE(t *testing.T) {
 	selectedAccountID := "test_account_1"
 	expectedAccountIDs := []string{selectedAccountID}
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs: expectedAccountIDs,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount("12323")
+	err := ctrl.SelectAccount(context.Background(), "12323")
 	require.Error(t, err)
 	require.EqualError(t, err, errorcode.NoMatchingAccountID)
 }
@@ -243,26 +509,26 @@ func TestGetBalance(t *testing.T) {
 	expectedAccountIDs := []string{selectedAccountID}
 	expectedBalanceAmt := 50
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:    expectedAccountIDs,
 			GetBalanceAmt: expectedBalanceAmt,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	balance, err := ctrl.GetBalance(selectedAccountID)
+	balance, err := ctrl.GetBalance(context.Background(), selectedAccountID)
 	require.NoError(t, err)
-	require.Equal(t, expectedBalanceAmt, balance)
+	require.Equal(t, usd(expectedBalanceAmt), balance)
 }
 
 func TestGetBalanceError(t *testing.T) {
@@ -270,27 +536,27 @@ func TestGetBalanceError(t *testing.T) {
 	expectedAccountIDs := []string{selectedAccountID}
 	expectedBalanceAmt := 50
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:      expectedAccountIDs,
 			ErrOnGetBalance: true,
 			GetBalanceAmt:   expectedBalanceAmt,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	balance, err := ctrl.GetBalance(selectedAccountID)
+	balance, err := ctrl.GetBalance(context.Background(), selectedAccountID)
 	require.Error(t, err)
-	require.Equal(t, -1, balance)
+	require.Equal(t, model.Money{}, balance)
 }
 
 func TestMakeDeposit(t *testing.T) {
@@ -301,31 +567,31 @@ func TestMakeDeposit(t *testing.T) {
 	depositAmount := 30
 	expectedBalanceAmtAfterDeposit := 80
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:          expectedAccountIDs,
 			GetBalanceAmt:       expectedBalanceAmt,
 			BalanceAfterDeposit: expectedBalanceAmtAfterDeposit,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	balance, err := ctrl.GetBalance(selectedAccountID)
+	balance, err := ctrl.GetBalance(context.Background(), selectedAccountID)
 	require.NoError(t, err)
-	require.Equal(t, expectedBalanceAmt, balance)
+	require.Equal(t, usd(expectedBalanceAmt), balance)
 
-	newBalance, err := ctrl.MakeDeposit(selectedAccountID, depositAmount)
+	newBalance, err := ctrl.MakeDeposit(context.Background(), selectedAccountID, usd(depositAmount))
 	require.NoError(t, err)
-	require.Equal(t, expectedBalanceAmtAfterDeposit, newBalance)
+	require.Equal(t, usd(expectedBalanceAmtAfterDeposit), newBalance)
 }
 
 func TestMakeDepositError(t *testing.T) {
@@ -336,32 +602,32 @@ func TestMakeDepositError(t *testing.T) {
 	depositAmount := 30
 	expectedBalanceAmtAfterDeposit := 80
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:          expectedAccountIDs,
 			GetBalanceAmt:       expectedBalanceAmt,
 			ErrOnMakeDeposit:    true,
 			BalanceAfterDeposit: expectedBalanceAmtAfterDeposit,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	balance, err := ctrl.GetBalance(selectedAccountID)
+	balance, err := ctrl.GetBalance(context.Background(), selectedAccountID)
 	require.NoError(t, err)
-	require.Equal(t, expectedBalanceAmt, balance)
+	require.Equal(t, usd(expectedBalanceAmt), balance)
 
-	newBalance, err := ctrl.MakeDeposit(selectedAccountID, depositAmount)
+	newBalance, err := ctrl.MakeDeposit(context.Background(), selectedAccountID, usd(depositAmount))
 	require.Error(t, err)
-	require.Equal(t, -1, newBalance)
+	require.Equal(t, model.Money{}, newBalance)
 }
 
 func TestMakeWithdrawal(t *testing.T) {
@@ -372,27 +638,27 @@ func TestMakeWithdrawal(t *testing.T) {
 	withdrawAmount := 30
 	expectedBalanceAmtAfterWithdrawl := 20
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:           expectedAccountIDs,
 			GetBalanceAmt:        expectedBalanceAmt,
 			BalanceAfterWithdraw: expectedBalanceAmtAfterWithdrawl,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	newBalance, err := ctrl.MakeWithdrawl(selectedAccountID, withdrawAmount)
+	newBalance, err := ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(withdrawAmount))
 	require.NoError(t, err)
-	require.Equal(t, expectedBalanceAmtAfterWithdrawl, newBalance)
+	require.Equal(t, usd(expectedBalanceAmtAfterWithdrawl), newBalance)
 }
 
 func TestMakeWithdrawInternalError(t *testing.T) {
@@ -402,27 +668,27 @@ func TestMakeWithdrawInternalError(t *testing.T) {
 	expectedBalanceAmt := 50
 	withdrawAmount := 30
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:    expectedAccountIDs,
 			GetBalanceAmt: expectedBalanceAmt,
 			ErrOnWithdraw: true,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	newBalance, err := ctrl.MakeWithdrawl(selectedAccountID, withdrawAmount)
+	newBalance, err := ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(withdrawAmount))
 	require.Error(t, err)
-	require.Equal(t, -1, newBalance)
+	require.Equal(t, model.Money{}, newBalance)
 }
 
 func TestMakeWithdraw_overdraw(t *testing.T) {
@@ -432,24 +698,179 @@ func TestMakeWithdraw_overdraw(t *testing.T) {
 	expectedBalanceAmt := 50
 	withdrawAmount := 80
 
-	ctrl := NewAtmController(Options{
-		cardSvc: testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}),
-		accountSvc: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
 			AccountIDs:    expectedAccountIDs,
 			GetBalanceAmt: expectedBalanceAmt,
-		}),
+		}), testutil.NewAccountAdapterOptions()),
 	})
-	_ = ctrl.InsertCard(model.Card{
+	_ = ctrl.InsertCard(context.Background(), model.Card{
 		HolderName: "test user",
-		Number:     "1234",
+		Number:     "4111111111111111",
 	})
 
-	_ = ctrl.EnterPin("123123231")
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
 
-	err := ctrl.SelectAccount(selectedAccountID)
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
 	require.NoError(t, err)
 
-	newBalance, err := ctrl.MakeWithdrawl(selectedAccountID, withdrawAmount)
+	newBalance, err := ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(withdrawAmount))
 	require.EqualError(t, err, errorcode.IsOverdraw)
-	require.Equal(t, -1, newBalance)
+	require.Equal(t, model.Money{}, newBalance)
+}
+
+func TestInvalidAmounts(t *testing.T) {
+	selectedAccountID := "test_account_1"
+
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			AccountIDs:    []string{selectedAccountID},
+			GetBalanceAmt: 100000,
+		}), testutil.NewAccountAdapterOptions()),
+		AmountPolicy: amountpolicy.Policy{
+			Currency:      testutil.Currency,
+			Denominations: []int64{2000, 5000},
+			MaxWithdrawal: usd(40000),
+			MaxDeposit:    usd(50000),
+		},
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
+
+	err := ctrl.SelectAccount(context.Background(), selectedAccountID)
+	require.NoError(t, err)
+
+	for amount, expected := range map[int]error{
+		-2000: errorcode.ErrNonPositiveAmount,
+		0:     errorcode.ErrNonPositiveAmount,
+		3000:  errorcode.ErrAmountNotDispensable,
+		42000: errorcode.ErrAmountAboveMaximum,
+	} {
+		_, err = ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(amount))
+		require.ErrorIs(t, err, expected, amount)
+	}
+
+	_, err = ctrl.MakeDeposit(context.Background(), selectedAccountID, usd(-1))
+	require.ErrorIs(t, err, errorcode.ErrNonPositiveAmount)
+	_, err = ctrl.MakeDeposit(context.Background(), selectedAccountID, usd(50001))
+	require.ErrorIs(t, err, errorcode.ErrAmountAboveMaximum)
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(7000))
+	require.NoError(t, err)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+}
+
+func TestMakeWithdrawalDispenser(t *testing.T) {
+	selectedAccountID := "test_account_1"
+
+	cash, err := dispenser.New(testutil.Currency, []dispenser.Cassette{
+		{ID: "twenties", Denomination: 2000, Count: 3},
+		{ID: "fifties", Denomination: 5000, Count: 2},
+	})
+	require.NoError(t, err)
+
+	newCtrl := func(acctOpts testutil.DummyAcctTestOptions) *AtmController {
+		acctOpts.AccountIDs = []string{selectedAccountID}
+		acctOpts.GetBalanceAmt = 100000
+		ctrl := newTestController(t, Options{
+			CardSvc:    service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+			AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(acctOpts), testutil.NewAccountAdapterOptions()),
+			Dispenser:  cash,
+		})
+		_ = ctrl.InsertCard(context.Background(), model.Card{
+			HolderName: "test user",
+			Number:     "4111111111111111",
+		})
+		_, _ = ctrl.EnterPin(context.Background(), "123123231")
+		require.NoError(t, ctrl.SelectAccount(context.Background(), selectedAccountID))
+
+		return ctrl
+	}
+
+	// The cassettes are checked before the account is debited.
+	ctrl := newCtrl(testutil.DummyAcctTestOptions{ErrOnWithdraw: true})
+	_, err = ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(3000))
+	require.ErrorIs(t, err, errorcode.ErrCashUnavailable)
+	_, err = ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(20000))
+	require.ErrorIs(t, err, errorcode.ErrCashUnavailable)
+
+	ctrl = newCtrl(testutil.DummyAcctTestOptions{BalanceAfterWithdraw: 94000})
+	newBalance, err := ctrl.MakeWithdrawlWithPreference(context.Background(), selectedAccountID, usd(6000), dispenser.Preference{Denomination: 2000})
+	require.NoError(t, err)
+	require.Equal(t, usd(94000), newBalance)
+
+	cassettes := cash.Cassettes()
+	require.Equal(t, 0, cassettes[0].Count)
+	require.Equal(t, 2, cassettes[1].Count)
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), selectedAccountID, usd(2000))
+	require.ErrorIs(t, err, errorcode.ErrCashUnavailable)
+}
+
+func TestCancelledContext(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ctrl.EnterPin(ctx, "123123231")
+	require.ErrorIs(t, err, errorcode.ErrPinNumberCheckFail)
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, ctrl.session.IsPinNumValidated())
+}
+
+func TestSessionState(t *testing.T) {
+	selectedAccountID := "test_account_1"
+
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			AccountIDs: []string{selectedAccountID},
+		}), testutil.NewAccountAdapterOptions()),
+	})
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+	require.Equal(t, atmcontext.CardInserted, ctrl.State())
+
+	err := ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "other user",
+		Number:     "5500000000000004",
+	})
+	require.ErrorIs(t, err, errorcode.ErrInsertCardFail)
+	require.ErrorIs(t, err, errorcode.ErrIllegalTransition)
+
+	_, err = ctrl.GetBalance(context.Background(), selectedAccountID)
+	require.ErrorIs(t, err, errorcode.ErrPinNumberNotValidated)
+
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
+	require.Equal(t, atmcontext.Authenticated, ctrl.State())
+
+	_, err = ctrl.GetBalance(context.Background(), selectedAccountID)
+	require.ErrorIs(t, err, errorcode.ErrNoAccountSelected)
+
+	_ = ctrl.SelectAccount(context.Background(), selectedAccountID)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+
+	_, err = ctrl.GetBalance(context.Background(), selectedAccountID)
+	require.NoError(t, err)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+
+	_ = ctrl.RemoveCard(context.Background())
+	require.Equal(t, atmcontext.Idle, ctrl.State())
 }
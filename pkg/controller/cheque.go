@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/journal"
+	"atm/pkg/micr"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"atm/pkg/velocity"
+	"context"
+	"fmt"
+)
+
+// DepositCheque deposits the cheque with the given MICR line for the
+// declared amount. The funds are placed on hold by the account service
+// rather than credited as available.
+func (ctrl *AtmController) DepositCheque(ctx context.Context, accountID string, micrLine string, declared model.Money) (service.Hold, error) {
+	if !ctrl.mu.TryLock() {
+		return service.Hold{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.requireAccount(accountID); err != nil {
+		return service.Hold{}, err
+	}
+	defer ctrl.session.EndTransaction()
+
+	line, err := micr.Parse(micrLine)
+	if err != nil {
+		return service.Hold{}, err
+	}
+	if err := ctrl.amounts.ValidateDeposit(declared); err != nil {
+		return service.Hold{}, err
+	}
+	if line.HasAmount && line.Amount != declared.Amount {
+		return service.Hold{}, errorcode.Wrap(errorcode.ChequeAmountMismatch, fmt.Errorf("declared %s, encoded %d", declared, line.Amount))
+	}
+	if err := ctrl.checkVelocity(velocity.Deposit, accountID, declared); err != nil {
+		return service.Hold{}, err
+	}
+
+	hold, err := ctrl.accountSvc.DepositCheque(ctx, accountID, service.Cheque{MICR: line, Amount: declared})
+	if err != nil {
+		return service.Hold{}, errorcode.WrapRetryable(errorcode.FailedToDepositCheque, err)
+	}
+	ctrl.record(journal.Entry{Event: journal.ChequeHeld, AccountID: accountID, Amount: hold.Amount})
+
+	return hold, nil
+}
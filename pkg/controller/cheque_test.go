@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDepositCheque(t *testing.T) {
+	var deposited string
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{DepositedCheque: &deposited}, Options{Journal: entries})
+
+	hold, err := ctrl.DepositCheque(context.Background(), "test_account_1", "⑆123456780⑆ 12345678⑈ 0402", usd(12500))
+	require.NoError(t, err)
+	require.Equal(t, service.Hold{ID: "hold-1", Amount: usd(12500)}, hold)
+	require.Equal(t, "U0402U T123456780T 12345678U", deposited)
+	require.Equal(t, []journal.Event{journal.ChequeHeld}, journalEvents(entries))
+
+	_, err = ctrl.DepositCheque(context.Background(), "test_account_1", "⑆123456780⑆ 12345678⑈ ⑇0000012500⑇", usd(12500))
+	require.NoError(t, err)
+}
+
+func TestDepositChequeErrors(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{})
+
+	for _, tc := range []struct {
+		line     string
+		declared model.Money
+		expected error
+	}{
+		{line: "⑆12345678⑆ 12345678⑈", declared: usd(100), expected: errorcode.ErrMICRParseFail},
+		{line: "⑆123456789⑆ 12345678⑈", declared: usd(100), expected: errorcode.ErrRoutingCheckFail},
+		{line: "⑆123456780⑆ 12345678⑈", declared: usd(0), expected: errorcode.ErrNonPositiveAmount},
+		{line: "⑆123456780⑆ 12345678⑈ ⑇0000012500⑇", declared: usd(12000), expected: errorcode.ErrChequeAmountMismatch},
+	} {
+		_, err := ctrl.DepositCheque(context.Background(), "test_account_1", tc.line, tc.declared)
+		require.ErrorIs(t, err, tc.expected, tc.line)
+	}
+
+	ctrl = newSelectedController(t, testutil.DummyAcctTestOptions{ErrOnDepositCheque: true}, Options{})
+	_, err := ctrl.DepositCheque(context.Background(), "test_account_1", "⑆123456780⑆ 12345678⑈", usd(100))
+	require.ErrorIs(t, err, errorcode.ErrFailedToDepositCheque)
+	require.True(t, errorcode.IsRetryable(err))
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentOperationRejected(t *testing.T) {
+	selectedAccountID := "test_account_1"
+	block := make(chan struct{})
+
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			AccountIDs:      []string{selectedAccountID},
+			GetBalanceAmt:   50,
+			GetBalanceBlock: block,
+		}), testutil.NewAccountAdapterOptions()),
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
+	_ = ctrl.SelectAccount(context.Background(), selectedAccountID)
+
+	done := make(chan error)
+	go func() {
+		_, err := ctrl.GetBalance(context.Background(), selectedAccountID)
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		return ctrl.State() == atmcontext.InTransaction
+	}, time.Second, time.Millisecond)
+
+	_, err := ctrl.GetBalance(context.Background(), selectedAccountID)
+	require.ErrorIs(t, err, errorcode.ErrOperationInProgress)
+	require.ErrorIs(t, ctrl.RemoveCard(context.Background()), errorcode.ErrOperationInProgress)
+
+	action, err := ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutNone, action)
+
+	close(block)
+	require.NoError(t, <-done)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+}
+
+func TestConcurrentUse(t *testing.T) {
+	selectedAccountID := "test_account_1"
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			AccountIDs:    []string{selectedAccountID},
+			GetBalanceAmt: 50,
+		}), testutil.NewAccountAdapterOptions()),
+		Clock: clk,
+	})
+	_ = ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+	_, _ = ctrl.EnterPin(context.Background(), "123123231")
+	_ = ctrl.SelectAccount(context.Background(), selectedAccountID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := ctrl.GetBalance(context.Background(), selectedAccountID)
+				if err != nil && !errors.Is(err, errorcode.ErrOperationInProgress) {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _ = ctrl.CheckTimeout(context.Background())
+				_ = ctrl.State()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+}
+
+func TestWatchTimeouts(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{Clock: clk, IdleTimeout: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.WatchTimeouts(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	clk.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return ctrl.State() == atmcontext.Idle
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
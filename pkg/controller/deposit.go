@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"atm/pkg/acceptor"
+	"atm/pkg/errorcode"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"atm/pkg/velocity"
+	"context"
+)
+
+// CashDeposit is the content of the acceptor's escrow, shown to the
+// customer for confirmation.
+type CashDeposit struct {
+	Total model.Money
+	// Notes is the number of accepted notes per denomination.
+	Notes map[int64]int
+	// Unrecognised and Suspect are the numbers of notes handed back.
+	Unrecognised int
+	Suspect      int
+}
+
+// escrow is a cash deposit counted into the acceptor but not yet credited.
+type escrow struct {
+	accountID string
+	total     model.Money
+	// stacked is set once the notes are in the cash box and can no longer
+	// be handed back.
+	stacked bool
+}
+
+// BeginCashDeposit counts the customer's notes into escrow and returns the
+// counted total. The session stays in its transaction until the customer
+// calls ConfirmCashDeposit or CancelCashDeposit.
+func (ctrl *AtmController) BeginCashDeposit(ctx context.Context, accountID string) (CashDeposit, error) {
+	if !ctrl.mu.TryLock() {
+		return CashDeposit{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.acceptor == nil {
+		return CashDeposit{}, errorcode.ErrUnsupported
+	}
+	if err := ctrl.requireAccount(accountID); err != nil {
+		return CashDeposit{}, err
+	}
+
+	bundle, err := ctrl.acceptor.Accept(ctx)
+	if err != nil {
+		_ = ctrl.session.EndTransaction()
+		return CashDeposit{}, errorcode.Wrap(errorcode.AcceptNotesFail, err)
+	}
+
+	deposit := CashDeposit{
+		Total:        bundle.Total(),
+		Notes:        bundle.Denominations(),
+		Unrecognised: bundle.Count(acceptor.Unrecognised),
+		Suspect:      bundle.Count(acceptor.Suspect),
+	}
+	if deposit.Suspect > 0 {
+		ctrl.logger.Warn("suspect notes returned", "count", deposit.Suspect)
+	}
+	if !deposit.Total.IsPositive() {
+		_ = ctrl.session.EndTransaction()
+		return deposit, errorcode.ErrNoNotesAccepted
+	}
+	ctrl.record(journal.Entry{Event: journal.Escrowed, AccountID: accountID, Amount: deposit.Total})
+
+	err = ctrl.amounts.ValidateDeposit(deposit.Total)
+	if err == nil {
+		err = ctrl.checkVelocity(velocity.Deposit, accountID, deposit.Total)
+	}
+	if err != nil {
+		if returnErr := ctrl.returnNotes(ctx, accountID, deposit.Total); returnErr != nil {
+			err = returnErr
+		}
+		_ = ctrl.session.EndTransaction()
+		return deposit, err
+	}
+
+	ctrl.escrow = &escrow{accountID: accountID, total: deposit.Total}
+
+	return deposit, nil
+}
+
+// ConfirmCashDeposit stacks the notes in escrow and credits them to the
+// account. If crediting fails the notes stay stacked and the deposit stays
+// pending, so ConfirmCashDeposit can be retried.
+func (ctrl *AtmController) ConfirmCashDeposit(ctx context.Context) (model.Money, error) {
+	if !ctrl.mu.TryLock() {
+		return model.Money{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.escrow == nil {
+		return model.Money{}, errorcode.ErrNoDepositPending
+	}
+	ctrl.touch()
+	e := ctrl.escrow
+
+	held, err := ctrl.depositHolds(e.total)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	if !e.stacked {
+		if err := ctrl.acceptor.Stack(ctx); err != nil {
+			ctrl.logger.Error("stacking notes failed", "amount", e.total, "err", err)
+			return model.Money{}, errorcode.Wrap(errorcode.StackNotesFail, err)
+		}
+		e.stacked = true
+		ctrl.record(journal.Entry{Event: journal.Stacked, AccountID: e.accountID, Amount: e.total})
+	}
+
+	newBalance, err := ctrl.accountSvc.DepositWithHolds(ctx, e.accountID, e.total, held)
+	if err != nil {
+		ctrl.record(journal.Entry{Event: journal.CreditFailed, AccountID: e.accountID, Amount: e.total, Err: err.Error()})
+		return model.Money{}, errorcode.WrapRetryable(errorcode.FailedToMakeDeposit, err)
+	}
+	ctrl.record(journal.Entry{Event: journal.Credited, AccountID: e.accountID, Amount: e.total})
+
+	ctrl.escrow = nil
+
+	return newBalance, ctrl.session.EndTransaction()
+}
+
+// CancelCashDeposit hands the notes in escrow back to the customer.
+func (ctrl *AtmController) CancelCashDeposit(ctx context.Context) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.escrow == nil {
+		return errorcode.ErrNoDepositPending
+	}
+	if ctrl.escrow.stacked {
+		return errorcode.ErrNotesStacked
+	}
+	ctrl.touch()
+
+	if err := ctrl.returnNotes(ctx, ctrl.escrow.accountID, ctrl.escrow.total); err != nil {
+		return err
+	}
+	ctrl.escrow = nil
+
+	return ctrl.session.EndTransaction()
+}
+
+func (ctrl *AtmController) returnNotes(ctx context.Context, accountID string, total model.Money) error {
+	if err := ctrl.acceptor.Return(ctx); err != nil {
+		ctrl.logger.Error("returning notes failed", "amount", total, "err", err)
+		return errorcode.Wrap(errorcode.ReturnNotesFail, err)
+	}
+	ctrl.record(journal.Entry{Event: journal.NotesReturned, AccountID: accountID, Amount: total})
+
+	return nil
+}
+
+// abandonEscrow ends a pending cash deposit when the session is ending
+// without the customer's answer: notes still in escrow are handed back.
+// Stacked notes that were never credited are left to the journal for
+// reconciliation.
+func (ctrl *AtmController) abandonEscrow(ctx context.Context) {
+	e := ctrl.escrow
+	if e == nil {
+		return
+	}
+	ctrl.escrow = nil
+
+	if e.stacked {
+		ctrl.logger.Error("stacked deposit abandoned without credit", "amount", e.total)
+		ctrl.record(journal.Entry{Event: journal.CreditFailed, AccountID: e.accountID, Amount: e.total, Err: "session ended"})
+		return
+	}
+	_ = ctrl.returnNotes(ctx, e.accountID, e.total)
+}
+
+// depositHolds returns the holds the funds availability schedule places on
+// a cash deposit of amount made now.
+func (ctrl *AtmController) depositHolds(amount model.Money) ([]service.Hold, error) {
+	due, err := ctrl.holds.Holds(amount, ctrl.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var held []service.Hold
+	for _, h := range due {
+		held = append(held, service.Hold{Amount: h.Amount, ReleaseAt: h.ReleaseAt})
+	}
+
+	return held, nil
+}
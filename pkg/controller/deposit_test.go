@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"atm/pkg/acceptor"
+	"atm/pkg/amountpolicy"
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testNotes = []acceptor.Note{
+	{Denomination: 2000, Status: acceptor.Genuine},
+	{Denomination: 5000, Status: acceptor.Genuine},
+	{Denomination: 2000, Status: acceptor.Genuine},
+	{Status: acceptor.Unrecognised},
+	{Denomination: 10000, Status: acceptor.Suspect},
+}
+
+func TestCashDepositConfirm(t *testing.T) {
+	var stacked bool
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{BalanceAfterDeposit: 59000}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes, Stacked: &stacked}),
+		Journal:     entries,
+	})
+
+	deposit, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, CashDeposit{
+		Total:        usd(9000),
+		Notes:        map[int64]int{2000: 2, 5000: 1},
+		Unrecognised: 1,
+		Suspect:      1,
+	}, deposit)
+	require.Equal(t, atmcontext.InTransaction, ctrl.State())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrTransactionInProgress)
+
+	balance, err := ctrl.ConfirmCashDeposit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, usd(59000), balance)
+	require.True(t, stacked)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+	require.Equal(t, []journal.Event{journal.Escrowed, journal.Stacked, journal.Credited}, journalEvents(entries))
+
+	_, err = ctrl.ConfirmCashDeposit(context.Background())
+	require.ErrorIs(t, err, errorcode.ErrNoDepositPending)
+}
+
+func TestCashDepositCancel(t *testing.T) {
+	var returned bool
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes, Returned: &returned}),
+		Journal:     entries,
+	})
+
+	_, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.NoError(t, err)
+
+	require.NoError(t, ctrl.CancelCashDeposit(context.Background()))
+	require.True(t, returned)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+	require.Equal(t, []journal.Event{journal.Escrowed, journal.NotesReturned}, journalEvents(entries))
+
+	require.ErrorIs(t, ctrl.CancelCashDeposit(context.Background()), errorcode.ErrNoDepositPending)
+}
+
+func TestCashDepositCreditRetry(t *testing.T) {
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{ErrOnMakeDeposit: true}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes}),
+		Journal:     entries,
+	})
+
+	_, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.NoError(t, err)
+
+	_, err = ctrl.ConfirmCashDeposit(context.Background())
+	require.ErrorIs(t, err, errorcode.ErrFailedToMakeDeposit)
+	require.True(t, errorcode.IsRetryable(err))
+	require.Equal(t, atmcontext.InTransaction, ctrl.State())
+
+	// The notes are in the cash box now and cannot be handed back.
+	require.ErrorIs(t, ctrl.CancelCashDeposit(context.Background()), errorcode.ErrNotesStacked)
+
+	_, err = ctrl.ConfirmCashDeposit(context.Background())
+	require.ErrorIs(t, err, errorcode.ErrFailedToMakeDeposit)
+	require.Equal(t, []journal.Event{journal.Escrowed, journal.Stacked, journal.CreditFailed, journal.CreditFailed}, journalEvents(entries))
+}
+
+func TestCashDepositRejected(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: []acceptor.Note{{Status: acceptor.Unrecognised}}}),
+	})
+
+	deposit, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrNoNotesAccepted)
+	require.Equal(t, 1, deposit.Unrecognised)
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+
+	var returned bool
+	entries := journal.NewMemory()
+	ctrl = newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AcceptorSvc:  testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes, Returned: &returned}),
+		AmountPolicy: amountpolicy.Policy{MaxDeposit: usd(5000)},
+		Journal:      entries,
+	})
+
+	_, err = ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrAmountAboveMaximum)
+	require.True(t, returned)
+	require.Equal(t, []journal.Event{journal.Escrowed, journal.NotesReturned}, journalEvents(entries))
+	require.Equal(t, atmcontext.AccountSelected, ctrl.State())
+}
+
+func TestCashDepositTimeout(t *testing.T) {
+	var returned bool
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes, Returned: &returned}),
+		Clock:       clk,
+		IdleTimeout: time.Minute,
+	})
+
+	_, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.NoError(t, err)
+
+	clk.Advance(2 * time.Minute)
+	action, err := ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutEjected, action)
+	require.True(t, returned)
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+}
+
+func TestCashDepositUnsupported(t *testing.T) {
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+	})
+
+	_, err := ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+}
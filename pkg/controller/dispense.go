@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"context"
+	"errors"
+)
+
+// deliverCash runs the dispense lifecycle for a withdrawal of amount that
+// has already been debited: dispense, present, then taken or retracted.
+// Whatever part of amount does not reach the customer is reversed on the
+// account service; notes whose fate the dispenser cannot report are
+// journaled for reconciliation instead. It returns balance if all of amount
+// was delivered. Reversed amounts are given back to the withdrawal limits
+// through release.
+func (ctrl *AtmController) deliverCash(ctx context.Context, accountID string, amount model.Money, mix dispenser.Mix, balance model.Money, release func(model.Money)) (model.Money, error) {
+	// The account has been debited, so the lifecycle is seen through even if
+	// the caller gives up.
+	ctx = context.WithoutCancel(ctx)
+
+	picked, err := ctrl.dispense(ctx, mix)
+	ctrl.logCassettes()
+	if err != nil {
+		ctrl.logger.Error("cash dispense failed", "amount", amount, "err", err)
+		ctrl.record(journal.Entry{Event: journal.DispenseFailed, AccountID: accountID, Amount: amount, Notes: picked, Err: err.Error()})
+		if picked.Notes() == 0 {
+			return model.Money{}, ctrl.reverse(ctx, release, accountID, amount, errorcode.Wrap(errorcode.DispenseFail, err))
+		}
+	} else {
+		ctrl.record(journal.Entry{Event: journal.Dispensed, AccountID: accountID, Amount: amount, Notes: picked})
+	}
+
+	delivered := model.NewMoney(picked.Total(), amount.Currency)
+	if ctrl.cashSvc != nil {
+		taken, presentErr := ctrl.cashSvc.Present(ctx)
+		if presentErr != nil {
+			// The notes may have been taken before the fault, so only the
+			// part that was never picked is reversed.
+			ctrl.logger.Error("cash present failed", "amount", delivered, "err", presentErr)
+			ctrl.record(journal.Entry{Event: journal.PresentUnknown, AccountID: accountID, Amount: delivered, Notes: picked, Err: presentErr.Error()})
+			cause := errorcode.Wrap(errorcode.PresentUnknown, presentErr)
+			shortfall, subErr := amount.Sub(delivered)
+			if subErr != nil {
+				return model.Money{}, subErr
+			}
+			if shortfall.IsPositive() {
+				return model.Money{}, ctrl.reverse(ctx, release, accountID, shortfall, cause)
+			}
+			return model.Money{}, cause
+		}
+		ctrl.record(journal.Entry{Event: journal.Presented, AccountID: accountID, Amount: delivered, Notes: picked})
+		if !taken {
+			ctrl.record(journal.Entry{Event: journal.Retracted, AccountID: accountID, Amount: delivered})
+			return model.Money{}, ctrl.reverse(ctx, release, accountID, amount, errorcode.ErrCashRetracted)
+		}
+		ctrl.record(journal.Entry{Event: journal.Taken, AccountID: accountID, Amount: delivered})
+	}
+
+	shortfall, subErr := amount.Sub(delivered)
+	if subErr != nil {
+		return model.Money{}, subErr
+	}
+	if shortfall.IsPositive() {
+		return model.Money{}, ctrl.reverse(ctx, release, accountID, shortfall, errorcode.Wrap(errorcode.PartialDispense, err))
+	}
+
+	return balance, nil
+}
+
+// dispense picks mix and takes the picked notes out of the inventory.
+func (ctrl *AtmController) dispense(ctx context.Context, mix dispenser.Mix) (dispenser.Mix, error) {
+	if ctrl.cashSvc == nil {
+		if err := ctrl.dispenser.Dispense(mix); err != nil {
+			return nil, err
+		}
+		return mix, nil
+	}
+
+	picked, err := ctrl.cashSvc.Dispense(ctx, mix)
+	if invErr := ctrl.dispenser.Dispense(picked); invErr != nil {
+		ctrl.logger.Error("cassette inventory out of step with dispenser", "err", invErr)
+	}
+
+	return picked, err
+}
+
+// reverse credits amount back to accountID, gives it back to the limits
+// through release, and returns cause, joined with an errorcode.ReversalFail
+// error if the reversal itself fails.
+func (ctrl *AtmController) reverse(ctx context.Context, release func(model.Money), accountID string, amount model.Money, cause error) error {
+	_, err := ctrl.accountSvc.Reverse(ctx, accountID, amount)
+	if err != nil {
+		ctrl.logger.Error("withdrawal reversal failed", "amount", amount, "err", err)
+		ctrl.record(journal.Entry{Event: journal.ReversalFailed, AccountID: accountID, Amount: amount, Err: err.Error()})
+		return errors.Join(cause, errorcode.Wrap(errorcode.ReversalFail, err))
+	}
+	ctrl.record(journal.Entry{Event: journal.Reversed, AccountID: accountID, Amount: amount})
+	release(amount)
+
+	return cause
+}
+
+// record stamps entry with the time and terminal and writes it to the
+// journal.
+func (ctrl *AtmController) record(entry journal.Entry) {
+	entry.Time = ctrl.clock.Now()
+	entry.TerminalID = ctrl.terminalID
+	if err := ctrl.journal.Record(entry); err != nil {
+		ctrl.logger.Error("journal write failed", "event", entry.Event, "err", err)
+	}
+}
+
+// logCassettes warns about cassettes that are running low or are empty.
+func (ctrl *AtmController) logCassettes() {
+	for _, c := range ctrl.dispenser.Cassettes() {
+		if status := c.Status(); status != dispenser.OK {
+			ctrl.logger.Warn("cassette "+status.String(), "cassette", c.ID, "denomination", c.Denomination, "count", c.Count)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newTestDispenser returns a dispenser holding ten 20.00 and ten 50.00
+// notes.
+func newTestDispenser(t *testing.T) *dispenser.Dispenser {
+	t.Helper()
+
+	cash, err := dispenser.New(testutil.Currency, []dispenser.Cassette{
+		{ID: "twenties", Denomination: 2000, Count: 10},
+		{ID: "fifties", Denomination: 5000, Count: 10},
+	})
+	require.NoError(t, err)
+
+	return cash
+}
+
+func journalEvents(entries *journal.Memory) []journal.Event {
+	var events []journal.Event
+	for _, e := range entries.Entries() {
+		events = append(events, e.Event)
+	}
+
+	return events
+}
+
+func TestDispenseTaken(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt:        100000,
+		BalanceAfterWithdraw: 88000,
+		Reversed:             &reversed,
+	}, Options{
+		Dispenser:  cash,
+		CashSvc:    testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{}),
+		Journal:    entries,
+		TerminalID: "T1",
+	})
+
+	balance, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.NoError(t, err)
+	require.Equal(t, usd(88000), balance)
+	require.Zero(t, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.Dispensed, journal.Presented, journal.Taken}, journalEvents(entries))
+	for _, e := range entries.Entries() {
+		require.Equal(t, "T1", e.TerminalID)
+		require.Equal(t, "test_account_1", e.AccountID)
+	}
+	require.Equal(t, 9, cash.Cassettes()[0].Count)
+	require.Equal(t, 8, cash.Cassettes()[1].Count)
+}
+
+func TestDispenseJam(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		Reversed:      &reversed,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{JamAfterNotes: -1}),
+		Journal:   entries,
+	})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrDispenseFail)
+	require.False(t, errorcode.IsRetryable(err))
+	require.Equal(t, 12000, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.DispenseFailed, journal.Reversed}, journalEvents(entries))
+	require.Equal(t, 10, cash.Cassettes()[0].Count)
+	require.Equal(t, 10, cash.Cassettes()[1].Count)
+}
+
+func TestDispensePartial(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		Reversed:      &reversed,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{JamAfterNotes: 2}),
+		Journal:   entries,
+	})
+
+	// 120 is one twenty and two fifties; the jam leaves the last fifty behind.
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrPartialDispense)
+	require.Equal(t, 5000, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.DispenseFailed, journal.Presented, journal.Taken, journal.Reversed}, journalEvents(entries))
+	last := entries.Entries()[4]
+	require.Equal(t, usd(5000), last.Amount)
+	require.Equal(t, 9, cash.Cassettes()[0].Count)
+	require.Equal(t, 9, cash.Cassettes()[1].Count)
+}
+
+func TestDispenseRetracted(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		Reversed:      &reversed,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{NotTaken: true}),
+		Journal:   entries,
+	})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrCashRetracted)
+	require.Equal(t, 12000, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.Dispensed, journal.Presented, journal.Retracted, journal.Reversed}, journalEvents(entries))
+}
+
+func TestDispensePresentUnknown(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		Reversed:      &reversed,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{ErrOnPresent: true}),
+		Journal:   entries,
+	})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrPresentUnknown)
+	require.False(t, errorcode.IsRetryable(err))
+	require.Zero(t, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.Dispensed, journal.PresentUnknown}, journalEvents(entries))
+	require.Equal(t, usd(12000), entries.Entries()[2].Amount)
+}
+
+func TestDispensePartialPresentUnknown(t *testing.T) {
+	var reversed int
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		Reversed:      &reversed,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{JamAfterNotes: 2, ErrOnPresent: true}),
+		Journal:   entries,
+	})
+
+	// Only the fifty that was never picked is known not to have been delivered.
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrPresentUnknown)
+	require.Equal(t, 5000, reversed)
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.DispenseFailed, journal.PresentUnknown, journal.Reversed}, journalEvents(entries))
+}
+
+func TestDispenseReversalFail(t *testing.T) {
+	cash, entries := newTestDispenser(t), journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		ErrOnReverse:  true,
+	}, Options{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{NotTaken: true}),
+		Journal:   entries,
+	})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(12000))
+	require.ErrorIs(t, err, errorcode.ErrCashRetracted)
+	require.ErrorIs(t, err, errorcode.ErrReversalFail)
+	require.Equal(t, errorcode.CashRetracted, errorcode.Code(err))
+
+	require.Equal(t, []journal.Event{journal.Debited, journal.Dispensed, journal.Presented, journal.Retracted, journal.ReversalFailed}, journalEvents(entries))
+}
+
+func TestCashSvcNeedsDispenser(t *testing.T) {
+	_, err := NewAtmController(Options{
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), testutil.NewAccountAdapterOptions()),
+		CardSvc:    service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		PinCipher:  testutil.NewPinCipher(),
+		CashSvc:    testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{}),
+	})
+	require.ErrorIs(t, err, errorcode.ErrNilDispenser)
+}
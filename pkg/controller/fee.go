@@ -0,0 +1,119 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"atm/pkg/fees"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"context"
+	"fmt"
+)
+
+// feeQuote is a fee shown to the customer for one transaction.
+type feeQuote struct {
+	txn       fees.Transaction
+	accountID string
+	amount    model.Money
+	fee       model.Money
+	accepted  bool
+}
+
+// QuoteFee returns the fee for txn of amount on accountID so it can be
+// shown to the customer; amount is zero for balance inquiries. A positive
+// fee must be accepted with AcceptFee before the same transaction is made,
+// otherwise it fails with errorcode.ErrFeeNotAccepted.
+func (ctrl *AtmController) QuoteFee(txn fees.Transaction, accountID string, amount model.Money) (model.Money, error) {
+	if !ctrl.mu.TryLock() {
+		return model.Money{}, errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.session.Require(atmcontext.AccountSelected); err != nil {
+		return model.Money{}, err
+	}
+	if ctrl.session.GetAccountID() != accountID {
+		return model.Money{}, errorcode.ErrAccountIDMismatch
+	}
+	ctrl.touch()
+
+	fee, err := ctrl.fee(txn, amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+	ctrl.feeQuote = &feeQuote{txn: txn, accountID: accountID, amount: amount, fee: fee}
+
+	return fee, nil
+}
+
+// AcceptFee records the customer's acceptance of the last quoted fee.
+func (ctrl *AtmController) AcceptFee() error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.feeQuote == nil {
+		return errorcode.ErrNoFeeQuoted
+	}
+	ctrl.touch()
+	ctrl.feeQuote.accepted = true
+
+	return nil
+}
+
+// DeclineFee drops the last quoted fee.
+func (ctrl *AtmController) DeclineFee() error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.feeQuote == nil {
+		return errorcode.ErrNoFeeQuoted
+	}
+	ctrl.touch()
+	ctrl.feeQuote = nil
+
+	return nil
+}
+
+func (ctrl *AtmController) fee(txn fees.Transaction, amount model.Money) (model.Money, error) {
+	if ctrl.fees == nil {
+		return model.Money{}, nil
+	}
+
+	return ctrl.fees.Fee(*ctrl.session.ViewCard(), txn, amount)
+}
+
+// requireFee works out the fee for txn and, if there is one, checks that
+// the customer accepted exactly that fee for this transaction.
+func (ctrl *AtmController) requireFee(txn fees.Transaction, accountID string, amount model.Money) (model.Money, error) {
+	fee, err := ctrl.fee(txn, amount)
+	if err != nil || !fee.IsPositive() {
+		return fee, err
+	}
+
+	q := ctrl.feeQuote
+	if q == nil || !q.accepted || q.txn != txn || q.accountID != accountID || q.amount != amount || q.fee != fee {
+		return model.Money{}, errorcode.Wrap(errorcode.FeeNotAccepted, fmt.Errorf("%s %s", txn, fee))
+	}
+
+	return fee, nil
+}
+
+// chargeFee posts fee as its own line on accountID and uses up the quote.
+func (ctrl *AtmController) chargeFee(ctx context.Context, txn fees.Transaction, accountID string, fee model.Money) (model.Money, error) {
+	ctrl.feeQuote = nil
+
+	balance, err := ctrl.accountSvc.ChargeFee(ctx, accountID, service.Fee{Description: string(txn) + " fee", Amount: fee})
+	if err != nil {
+		ctrl.logger.Error("fee charge failed", "transaction", txn, "fee", fee, "err", err)
+		ctrl.record(journal.Entry{Event: journal.FeeChargeFail, AccountID: accountID, Amount: fee, Err: err.Error()})
+		return model.Money{}, errorcode.WrapRetryable(errorcode.FailedToChargeFee, err)
+	}
+	ctrl.record(journal.Entry{Event: journal.FeeCharged, AccountID: accountID, Amount: fee})
+
+	return balance, nil
+}
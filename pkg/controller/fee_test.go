@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/fees"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var testFees = &fees.Schedule{
+	OnUs: map[fees.Transaction]fees.Rule{
+		fees.Withdrawal: {Fixed: usd(100)},
+	},
+	Foreign: map[fees.Transaction]fees.Rule{
+		fees.Withdrawal:     {Fixed: usd(250), BasisPoints: 100},
+		fees.BalanceInquiry: {Fixed: usd(150)},
+	},
+	OnUsBINs: []string{"411111"},
+}
+
+// foreignFees is testFees without the on-us BINs, so the test card is
+// charged the foreign fees.
+var foreignFees = &fees.Schedule{
+	OnUs:    testFees.OnUs,
+	Foreign: testFees.Foreign,
+}
+
+func TestWithdrawalFee(t *testing.T) {
+	var charged []string
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt:        100000,
+		BalanceAfterWithdraw: 90000,
+		BalanceAfterFee:      89650,
+		ChargedFees:          &charged,
+	}, Options{Fees: foreignFees, Journal: entries})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrFeeNotAccepted)
+
+	fee, err := ctrl.QuoteFee(fees.Withdrawal, "test_account_1", usd(10000))
+	require.NoError(t, err)
+	require.Equal(t, usd(350), fee)
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrFeeNotAccepted)
+
+	require.NoError(t, ctrl.AcceptFee())
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(20000))
+	require.ErrorIs(t, err, errorcode.ErrFeeNotAccepted)
+
+	balance, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.NoError(t, err)
+	require.Equal(t, usd(89650), balance)
+	require.Equal(t, []string{"withdrawal fee"}, charged)
+	require.Equal(t, []journal.Event{journal.Debited, journal.FeeCharged}, journalEvents(entries))
+
+	// The acceptance covers one withdrawal only.
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrFeeNotAccepted)
+}
+
+func TestWithdrawalFeeOverdraw(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{GetBalanceAmt: 10000}, Options{Fees: foreignFees})
+
+	_, err := ctrl.QuoteFee(fees.Withdrawal, "test_account_1", usd(10000))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.AcceptFee())
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrIsOverdraw)
+}
+
+func TestWithdrawalFeeChargeFail(t *testing.T) {
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt:        100000,
+		BalanceAfterWithdraw: 90000,
+		ErrOnChargeFee:       true,
+	}, Options{Fees: testFees, Journal: entries})
+
+	fee, err := ctrl.QuoteFee(fees.Withdrawal, "test_account_1", usd(10000))
+	require.NoError(t, err)
+	require.Equal(t, usd(100), fee)
+	require.NoError(t, ctrl.AcceptFee())
+
+	balance, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.NoError(t, err)
+	require.Equal(t, usd(90000), balance)
+	require.Equal(t, []journal.Event{journal.Debited, journal.FeeChargeFail}, journalEvents(entries))
+}
+
+func TestBalanceInquiryFee(t *testing.T) {
+	var charged []string
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt:   100000,
+		BalanceAfterFee: 99850,
+		ChargedFees:     &charged,
+	}, Options{Fees: foreignFees, Journal: entries})
+
+	_, err := ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrFeeNotAccepted)
+
+	fee, err := ctrl.QuoteFee(fees.BalanceInquiry, "test_account_1", model.Money{})
+	require.NoError(t, err)
+	require.Equal(t, usd(150), fee)
+	require.NoError(t, ctrl.DeclineFee())
+	require.ErrorIs(t, ctrl.AcceptFee(), errorcode.ErrNoFeeQuoted)
+
+	_, err = ctrl.QuoteFee(fees.BalanceInquiry, "test_account_1", model.Money{})
+	require.NoError(t, err)
+	require.NoError(t, ctrl.AcceptFee())
+
+	balances, err := ctrl.GetBalances(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, usd(99850), balances.Ledger)
+	require.Equal(t, usd(99850), balances.Available)
+	require.Equal(t, []string{"balance inquiry fee"}, charged)
+	require.Equal(t, []journal.Event{journal.FeeCharged}, journalEvents(entries))
+}
+
+func TestBalanceInquiryFeeChargeFail(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{ErrOnChargeFee: true}, Options{Fees: foreignFees})
+
+	_, err := ctrl.QuoteFee(fees.BalanceInquiry, "test_account_1", model.Money{})
+	require.NoError(t, err)
+	require.NoError(t, ctrl.AcceptFee())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrFailedToChargeFee)
+	require.True(t, errorcode.IsRetryable(err))
+}
+
+func TestBalanceInquiryFeeReadFail(t *testing.T) {
+	var charged []string
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		ErrOnGetBalance: true,
+		ChargedFees:     &charged,
+	}, Options{Fees: foreignFees, Journal: entries})
+
+	_, err := ctrl.QuoteFee(fees.BalanceInquiry, "test_account_1", model.Money{})
+	require.NoError(t, err)
+	require.NoError(t, ctrl.AcceptFee())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrFailedToGetBalance)
+	require.Empty(t, charged)
+	require.Empty(t, entries.Entries())
+}
+
+func TestNoFeeOnUs(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{GetBalanceAmt: 100000}, Options{Fees: testFees})
+
+	fee, err := ctrl.QuoteFee(fees.BalanceInquiry, "test_account_1", model.Money{})
+	require.NoError(t, err)
+	require.True(t, fee.IsZero())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.NoError(t, err)
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/holds"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// testHolds makes the first 100.00 of a deposit available at once and
+// holds the rest until the next business day.
+var testHolds = holds.Policy{Rules: []holds.Rule{{Above: usd(10000), Days: 1}}}
+
+func TestDepositHolds(t *testing.T) {
+	// 2024-01-05 is a Friday.
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC))
+	var held []service.LegacyHold
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		BalanceAfterDeposit: 40000,
+		DepositedHolds:      &held,
+	}, Options{
+		AcceptorSvc: testutil.NewDummyAcceptorSvc(testutil.DummyAcceptorTestOptions{Notes: testNotes}),
+		Holds:       testHolds,
+		Clock:       clk,
+	})
+	monday := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	_, err := ctrl.MakeDeposit(context.Background(), "test_account_1", usd(25000))
+	require.NoError(t, err)
+	require.Equal(t, []service.LegacyHold{{Amount: 15000, ReleaseAt: monday}}, held)
+
+	// The cash deposit of 90.00 is under the threshold.
+	_, err = ctrl.BeginCashDeposit(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	_, err = ctrl.ConfirmCashDeposit(context.Background())
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+}
+
+func TestDepositHoldsUnsupported(t *testing.T) {
+	legacy := struct{ service.AccountInterface }{testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		AccountIDs: []string{"test_account_1"},
+	})}
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AccountSvc: service.AdaptAccount(legacy, testutil.NewAccountAdapterOptions()),
+		Holds:      testHolds,
+	})
+
+	_, err := ctrl.MakeDeposit(context.Background(), "test_account_1", usd(5000))
+	require.NoError(t, err)
+	_, err = ctrl.MakeDeposit(context.Background(), "test_account_1", usd(25000))
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+}
+
+func TestAvailableBalance(t *testing.T) {
+	release := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt:        50000,
+		BalanceAfterWithdraw: 10000,
+		Holds:                []service.LegacyHold{{ID: "hold-1", Amount: 30000, ReleaseAt: release}},
+	}, Options{})
+
+	balances, err := ctrl.GetBalances(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, service.Balances{
+		Ledger:    usd(50000),
+		Available: usd(20000),
+		Holds:     []service.Hold{{ID: "hold-1", Amount: usd(30000), ReleaseAt: release}},
+	}, balances)
+
+	balance, err := ctrl.GetBalance(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, usd(50000), balance)
+
+	// Funds on hold cannot be withdrawn.
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(30000))
+	require.ErrorIs(t, err, errorcode.ErrIsOverdraw)
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(20000))
+	require.NoError(t, err)
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/limits"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWithdrawalLimitsAcrossTerminals(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := limits.New(limits.Config{
+		Default: []limits.Limit{{Period: limits.Day, Card: usd(50000)}},
+	})
+	m := newTestManager(t, testutil.DummyAcctTestOptions{
+		AccountIDs:    []string{"test_account_1"},
+		GetBalanceAmt: 1000000,
+	}, Options{Limits: engine, Clock: clk})
+
+	session := func(terminalID string) *AtmController {
+		ctrl, err := m.Open(terminalID)
+		require.NoError(t, err)
+		selectAccount(t, ctrl, "4111111111111111", "test_account_1")
+
+		return ctrl
+	}
+
+	first := session("terminal-a")
+	_, err := first.MakeWithdrawl(context.Background(), "test_account_1", usd(40000))
+	require.NoError(t, err)
+
+	second := session("terminal-b")
+	_, err = second.MakeWithdrawl(context.Background(), "test_account_1", usd(20000))
+	require.ErrorIs(t, err, errorcode.ErrWithdrawalLimitExceeded)
+	_, err = second.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.NoError(t, err)
+
+	clk.Advance(24 * time.Hour)
+	_, err = second.MakeWithdrawl(context.Background(), "test_account_1", usd(50000))
+	require.NoError(t, err)
+}
+
+func TestWithdrawalLimitReversed(t *testing.T) {
+	engine := limits.New(limits.Config{
+		Default: []limits.Limit{{Period: limits.Day, Rolling: true, Account: usd(20000)}},
+	})
+	cash, err := dispenser.New(testutil.Currency, []dispenser.Cassette{
+		{ID: "twenties", Denomination: 2000, Count: 100},
+	})
+	require.NoError(t, err)
+
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 1000000,
+	}, Options{
+		Limits:    engine,
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{NotTaken: true}),
+	})
+
+	// Retracted cash is reversed, so it does not count towards the limit.
+	for range 3 {
+		_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(20000))
+		require.ErrorIs(t, err, errorcode.ErrCashRetracted)
+	}
+}
+
+func TestWithdrawalLimitsConcurrentTerminals(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := limits.New(limits.Config{
+		Default: []limits.Limit{{Period: limits.Day, Card: usd(50000)}},
+	})
+	started, block := make(chan struct{}, 2), make(chan struct{})
+	m := newTestManager(t, testutil.DummyAcctTestOptions{
+		AccountIDs:      []string{"test_account_1"},
+		GetBalanceAmt:   1000000,
+		WithdrawStarted: started,
+		WithdrawBlock:   block,
+	}, Options{Limits: engine, Clock: clk})
+
+	session := func(terminalID string) *AtmController {
+		ctrl, err := m.Open(terminalID)
+		require.NoError(t, err)
+		selectAccount(t, ctrl, "4111111111111111", "test_account_1")
+
+		return ctrl
+	}
+	first, second := session("terminal-a"), session("terminal-b")
+
+	// The first withdrawal holds its share of the limit while the account
+	// service is still debiting, so the second cannot take it too.
+	done := make(chan error)
+	go func() {
+		_, err := first.MakeWithdrawl(context.Background(), "test_account_1", usd(40000))
+		done <- err
+	}()
+	select {
+	case <-started:
+	case err := <-done:
+		t.Fatalf("first withdrawal did not reach the account service: %v", err)
+	}
+
+	secondDone := make(chan error)
+	go func() {
+		_, err := second.MakeWithdrawl(context.Background(), "test_account_1", usd(20000))
+		secondDone <- err
+	}()
+	select {
+	case err := <-secondDone:
+		require.ErrorIs(t, err, errorcode.ErrWithdrawalLimitExceeded)
+	case <-time.After(time.Second):
+		close(block)
+		t.Fatal("second withdrawal reached the account service")
+	}
+
+	close(block)
+	require.NoError(t, <-done)
+}
+
+func TestWithdrawalDebitUnknown(t *testing.T) {
+	engine := limits.New(limits.Config{
+		Default: []limits.Limit{{Period: limits.Day, Card: usd(15000)}},
+	})
+	block := make(chan struct{})
+	entries := journal.NewMemory()
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 1000000,
+		WithdrawBlock: block,
+	}, Options{Limits: engine, Journal: entries})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := ctrl.MakeWithdrawl(ctx, "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrDebitUnknown)
+	require.False(t, errorcode.IsRetryable(err))
+	require.Equal(t, []journal.Event{journal.DebitUnknown}, journalEvents(entries))
+
+	// The abandoned debit may still go through, so it keeps its share of
+	// the limit.
+	close(block)
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(10000))
+	require.ErrorIs(t, err, errorcode.ErrWithdrawalLimitExceeded)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/service"
+	"context"
+	"slices"
+	"sync"
+)
+
+// SessionManager runs many independent AtmControllers, one per terminal,
+// sharing the account and card services and the per-card PIN attempt
+// tracker given in its Options. Cash devices belong to one terminal and are
+// given to OpenWithDevices instead. It is safe for concurrent use.
+type SessionManager struct {
+	mu       sync.Mutex
+	opts     Options
+	sessions map[string]*AtmController
+}
+
+// NewSessionManager returns errorcode.ErrSharedDevices if opts sets
+// Dispenser, CashSvc or AcceptorSvc.
+func NewSessionManager(opts Options) (*SessionManager, error) {
+	if opts.Dispenser != nil || opts.CashSvc != nil || opts.AcceptorSvc != nil {
+		return nil, errorcode.ErrSharedDevices
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionManager{
+		opts:     opts,
+		sessions: make(map[string]*AtmController),
+	}, nil
+}
+
+// Devices are the cash devices of one terminal. See the Options fields of
+// the same names.
+type Devices struct {
+	Dispenser   *dispenser.Dispenser
+	CashSvc     service.DispenserInterface
+	AcceptorSvc service.AcceptorInterface
+}
+
+// Open creates a controller for terminalID with no cash devices.
+func (m *SessionManager) Open(terminalID string) (*AtmController, error) {
+	return m.OpenWithDevices(terminalID, Devices{})
+}
+
+// OpenWithDevices creates a controller for terminalID that drives devices.
+func (m *SessionManager) OpenWithDevices(terminalID string, devices Devices) (*AtmController, error) {
+	if terminalID == "" {
+		return nil, errorcode.ErrEmptyTerminalID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[terminalID]; ok {
+		return nil, errorcode.ErrSessionAlreadyOpen
+	}
+
+	opts := m.opts
+	opts.TerminalID = terminalID
+	opts.Dispenser = devices.Dispenser
+	opts.CashSvc = devices.CashSvc
+	opts.AcceptorSvc = devices.AcceptorSvc
+	ctrl, err := NewAtmController(opts)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[terminalID] = ctrl
+
+	return ctrl, nil
+}
+
+func (m *SessionManager) Lookup(terminalID string) (*AtmController, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ctrl, ok := m.sessions[terminalID]
+	if !ok {
+		return nil, errorcode.ErrSessionNotFound
+	}
+
+	return ctrl, nil
+}
+
+// Close removes the controller for terminalID. A terminal still holding a
+// card cannot be closed; eject or retain the card first. Closing a terminal
+// in the middle of an operation returns errorcode.ErrOperationInProgress.
+func (m *SessionManager) Close(terminalID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ctrl, ok := m.sessions[terminalID]
+	if !ok {
+		return errorcode.ErrSessionNotFound
+	}
+	// Hold the terminal's lock so that a card cannot be inserted between
+	// the state check and the removal.
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+	if ctrl.State() != atmcontext.Idle {
+		return errorcode.ErrSessionHasCard
+	}
+	delete(m.sessions, terminalID)
+
+	return nil
+}
+
+// Active returns the IDs of the open terminals in sorted order.
+func (m *SessionManager) Active() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// CheckTimeouts runs CheckTimeout on every open terminal.
+func (m *SessionManager) CheckTimeouts(ctx context.Context) {
+	m.mu.Lock()
+	ctrls := make([]*AtmController, 0, len(m.sessions))
+	for _, ctrl := range m.sessions {
+		ctrls = append(ctrls, ctrl)
+	}
+	m.mu.Unlock()
+
+	for _, ctrl := range ctrls {
+		if action, err := ctrl.CheckTimeout(ctx); err != nil {
+			ctrl.logger.Warn("timeout check failed", "action", action, "err", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/journal"
+	"atm/pkg/model"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestManager(t *testing.T, acctOpts testutil.DummyAcctTestOptions, opts Options) *SessionManager {
+	t.Helper()
+
+	m, err := NewSessionManager(testOptions(acctOpts, opts))
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestSessionManager(t *testing.T) {
+	m := newTestManager(t, testutil.DummyAcctTestOptions{}, Options{})
+
+	first, err := m.Open("terminal-b")
+	require.NoError(t, err)
+	second, err := m.Open("terminal-a")
+	require.NoError(t, err)
+	require.Equal(t, []string{"terminal-a", "terminal-b"}, m.Active())
+	require.Equal(t, "terminal-b", first.TerminalID())
+
+	_, err = m.Open("terminal-a")
+	require.ErrorIs(t, err, errorcode.ErrSessionAlreadyOpen)
+	_, err = m.Open("")
+	require.ErrorIs(t, err, errorcode.ErrEmptyTerminalID)
+
+	_ = first.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	})
+	require.Equal(t, atmcontext.CardInserted, first.State())
+	require.Equal(t, atmcontext.Idle, second.State())
+
+	found, err := m.Lookup("terminal-b")
+	require.NoError(t, err)
+	require.Same(t, first, found)
+
+	require.ErrorIs(t, m.Close("terminal-b"), errorcode.ErrSessionHasCard)
+	require.NoError(t, first.RemoveCard(context.Background()))
+	require.NoError(t, m.Close("terminal-b"))
+	require.ErrorIs(t, m.Close("terminal-b"), errorcode.ErrSessionNotFound)
+
+	_, err = m.Lookup("terminal-b")
+	require.ErrorIs(t, err, errorcode.ErrSessionNotFound)
+	require.Equal(t, []string{"terminal-a"}, m.Active())
+}
+
+func TestSessionManagerCloseBusy(t *testing.T) {
+	m := newTestManager(t, testutil.DummyAcctTestOptions{}, Options{})
+	ctrl, err := m.Open("terminal-a")
+	require.NoError(t, err)
+
+	// An operation holds the terminal's lock until it finishes.
+	ctrl.mu.Lock()
+	require.ErrorIs(t, m.Close("terminal-a"), errorcode.ErrOperationInProgress)
+	require.Equal(t, []string{"terminal-a"}, m.Active())
+	ctrl.mu.Unlock()
+
+	require.NoError(t, m.Close("terminal-a"))
+	require.Empty(t, m.Active())
+}
+
+func TestSessionManagerMissingServices(t *testing.T) {
+	_, err := NewSessionManager(Options{})
+	require.ErrorIs(t, err, errorcode.ErrNilAccountService)
+}
+
+func TestSessionManagerDevices(t *testing.T) {
+	cash, err := dispenser.New(testutil.Currency, []dispenser.Cassette{
+		{ID: "twenties", Denomination: 2000, Count: 10},
+	})
+	require.NoError(t, err)
+
+	_, err = NewSessionManager(Options{Dispenser: cash})
+	require.ErrorIs(t, err, errorcode.ErrSharedDevices)
+
+	entries := journal.NewMemory()
+	m := newTestManager(t, testutil.DummyAcctTestOptions{
+		AccountIDs:    []string{"test_account_1"},
+		GetBalanceAmt: 100000,
+	}, Options{Journal: entries})
+	ctrl, err := m.OpenWithDevices("terminal-a", Devices{
+		Dispenser: cash,
+		CashSvc:   testutil.NewDummyDispenserSvc(testutil.DummyDispenserTestOptions{}),
+	})
+	require.NoError(t, err)
+	selectAccount(t, ctrl, "4111111111111111", "test_account_1")
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(4000))
+	require.NoError(t, err)
+	require.Equal(t, 8, cash.Cassettes()[0].Count)
+	require.Equal(t, []journal.Event{journal.Debited, journal.Dispensed, journal.Presented, journal.Taken}, journalEvents(entries))
+
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(3000))
+	require.ErrorIs(t, err, errorcode.ErrCashUnavailable)
+}
+
+func TestSessionManagerSharesPinAttempts(t *testing.T) {
+	m := newTestManager(t, testutil.DummyAcctTestOptions{
+		InvalidPinNumberEnter: true,
+	}, Options{MaxCardPinAttempts: 2})
+	card := model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	}
+
+	first, _ := m.Open("terminal-a")
+	_ = first.InsertCard(context.Background(), card)
+	result, err := first.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinNumber)
+	require.Equal(t, 1, result.TriesRemaining)
+
+	second, _ := m.Open("terminal-b")
+	_ = second.InsertCard(context.Background(), card)
+	result, err = second.EnterPin(context.Background(), "123123231")
+	require.ErrorIs(t, err, errorcode.ErrCardRetained)
+	require.True(t, result.CardRetained)
+}
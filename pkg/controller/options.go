@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"atm/pkg/amountpolicy"
+	"atm/pkg/clock"
+	"atm/pkg/dispenser"
+	"atm/pkg/errorcode"
+	"atm/pkg/fees"
+	"atm/pkg/holds"
+	"atm/pkg/journal"
+	"atm/pkg/limits"
+	"atm/pkg/overdraft"
+	"atm/pkg/pinblock"
+	"atm/pkg/pinpolicy"
+	"atm/pkg/service"
+	"atm/pkg/velocity"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Options configures an AtmController. AccountSvc, CardSvc and PinCipher are required;
+// the remaining fields are optional and fall back to sensible defaults.
+// Legacy services can be passed through service.AdaptAccount and
+// service.AdaptCard.
+type Options struct {
+	AccountSvc service.AccountInterfaceV2
+	CardSvc    service.CardInterfaceV2
+	// PinCipher encrypts entered PINs into PIN blocks before they are sent
+	// to AccountSvc. Required.
+	PinCipher *pinblock.Cipher
+	// PinPolicy decides which new PINs ChangePin accepts. Defaults to
+	// pinpolicy.Default().
+	PinPolicy *pinpolicy.Policy
+	// AmountPolicy decides which deposit and withdrawal amounts are
+	// accepted. The zero policy, the default, accepts any positive amount.
+	AmountPolicy amountpolicy.Policy
+	// Fees is the surcharge schedule for withdrawals and balance inquiries.
+	// Nil charges no fees.
+	Fees *fees.Schedule
+	// Holds is the funds availability schedule for cash deposits. The zero
+	// value makes deposits available at once.
+	Holds holds.Policy
+	// Overdraft picks the overdraft policy the account service applies to
+	// each withdrawal. The zero value allows no overdraft.
+	Overdraft overdraft.Policies
+	// Limits caps cumulative withdrawals per card and account. Share one
+	// between controllers to enforce the limits across terminals. Nil
+	// disables the checks.
+	Limits *limits.Engine
+	// Velocity declines PIN entries, deposits and withdrawals that come too
+	// often or add up to too much. Share one between controllers to see
+	// activity across terminals. Nil disables the checks.
+	Velocity *velocity.Checker
+	// Dispenser is the terminal's cash dispenser. If set, withdrawals the
+	// cassettes cannot make up are refused before the account is debited.
+	// A dispenser belongs to one terminal, so SessionManager takes it,
+	// CashSvc and AcceptorSvc per terminal in OpenWithDevices.
+	Dispenser *dispenser.Dispenser
+	// CashSvc drives the dispenser hardware. If set, Dispenser is required
+	// and cash that is not delivered is reversed on AccountSvc. Without it,
+	// withdrawals only update the Dispenser inventory.
+	CashSvc service.DispenserInterface
+	// AcceptorSvc drives the cash deposit acceptor. Without it,
+	// BeginCashDeposit returns errorcode.ErrUnsupported.
+	AcceptorSvc service.AcceptorInterface
+	// Journal records each step of a cash withdrawal or deposit. Defaults to
+	// journal.Discard().
+	Journal journal.Journal
+
+	// TerminalID identifies the terminal the controller drives. It is set
+	// by SessionManager and added to every log line.
+	TerminalID string
+
+	// Clock defaults to the system clock.
+	Clock clock.Clock
+	// Logger defaults to a logger that discards everything.
+	Logger *slog.Logger
+
+	// MaxPinAttempts is the number of wrong PINs allowed in one session
+	// before the card is retained. Defaults to DefaultMaxPinAttempts.
+	MaxPinAttempts int
+	// MaxCardPinAttempts is the number of consecutive wrong PINs allowed for
+	// one card across sessions. Zero disables the per-card limit.
+	MaxCardPinAttempts int
+	// PinAttempts tracks per-card failures. Share one between controllers to
+	// enforce MaxCardPinAttempts across terminals.
+	PinAttempts *PinAttempts
+
+	// IdleTimeout is how long a session may go without customer activity
+	// before CheckTimeout ejects the card. Defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// EjectTimeout is how long an ejected card may wait to be taken before
+	// CheckTimeout retains it. Zero never retains.
+	EjectTimeout time.Duration
+}
+
+const (
+	DefaultMaxPinAttempts = 3
+	DefaultIdleTimeout    = 30 * time.Second
+)
+
+func (opts Options) withDefaults() (Options, error) {
+	if opts.AccountSvc == nil {
+		return opts, errorcode.ErrNilAccountService
+	}
+	if opts.CardSvc == nil {
+		return opts, errorcode.ErrNilCardService
+	}
+	if opts.PinCipher == nil {
+		return opts, errorcode.ErrNilPinCipher
+	}
+	if opts.CashSvc != nil && opts.Dispenser == nil {
+		return opts, errorcode.ErrNilDispenser
+	}
+	if opts.PinPolicy == nil {
+		policy := pinpolicy.Default()
+		opts.PinPolicy = &policy
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if opts.Journal == nil {
+		opts.Journal = journal.Discard()
+	}
+	if opts.MaxPinAttempts <= 0 {
+		opts.MaxPinAttempts = DefaultMaxPinAttempts
+	}
+	if opts.PinAttempts == nil {
+		opts.PinAttempts = NewPinAttempts()
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+
+	return opts, nil
+}
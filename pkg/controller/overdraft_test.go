@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/overdraft"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOverdraftPolicies(t *testing.T) {
+	policies := overdraft.Policies{
+		Default: overdraft.Policy{Kind: overdraft.FixedLimit, Limit: usd(5000)},
+		Accounts: map[string]overdraft.Policy{
+			"current": {Kind: overdraft.LinkedSweep, LinkedAccountID: "savings"},
+			"basic":   {Kind: overdraft.None},
+		},
+	}
+
+	for _, tc := range []struct {
+		accountID string
+		amount    int
+		swept     int
+		expected  error
+	}{
+		{accountID: "cheque", amount: 15000},
+		{accountID: "cheque", amount: 15100, expected: errorcode.ErrIsOverdraw},
+		{accountID: "current", amount: 12000, swept: 2000},
+		{accountID: "current", amount: 13100, expected: errorcode.ErrIsOverdraw},
+		{accountID: "basic", amount: 10000},
+		{accountID: "basic", amount: 10100, expected: errorcode.ErrIsOverdraw},
+	} {
+		t.Run(tc.accountID, func(t *testing.T) {
+			var swept int
+			ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+				AccountIDs:       []string{tc.accountID},
+				GetBalanceAmt:    10000,
+				LinkedBalanceAmt: 3000,
+				Swept:            &swept,
+			}, Options{Overdraft: policies})
+
+			_, err := ctrl.MakeWithdrawl(context.Background(), tc.accountID, usd(tc.amount))
+			if tc.expected != nil {
+				require.ErrorIs(t, err, tc.expected)
+				require.False(t, errorcode.IsRetryable(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.swept, swept)
+		})
+	}
+}
+
+func TestOverdraftPolicyUnsupported(t *testing.T) {
+	legacy := struct{ service.AccountInterface }{testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		AccountIDs:    []string{"test_account_1"},
+		GetBalanceAmt: 10000,
+	})}
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{
+		AccountSvc: service.AdaptAccount(legacy, testutil.NewAccountAdapterOptions()),
+		Overdraft:  overdraft.Policies{Default: overdraft.Policy{Kind: overdraft.FixedLimit, Limit: usd(5000)}},
+	})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(2000))
+	require.ErrorIs(t, err, errorcode.ErrFailedToWithdraw)
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+	require.False(t, errorcode.IsRetryable(err))
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"atm/pkg/internal/testutil"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const testPan = "4111111111111111"
+
+// TestNoFullPanEmitted drives the controller through its success and failure
+// paths and checks that neither the log output nor any returned error contains
+// the full card number.
+func TestNoFullPanEmitted(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cardOpts testutil.DummyCardTestOptions
+		acctOpts testutil.DummyAcctTestOptions
+	}{
+		{name: "success", acctOpts: testutil.DummyAcctTestOptions{AccountIDs: []string{"test_account_1"}, GetBalanceAmt: 50}},
+		{name: "insert fails", cardOpts: testutil.DummyCardTestOptions{ErrOnInsert: true}},
+		{name: "remove fails", cardOpts: testutil.DummyCardTestOptions{ErrOnRemove: true}},
+		{name: "pin check fails", acctOpts: testutil.DummyAcctTestOptions{ErrOnPinNumberEnter: true}},
+		{name: "card retained", acctOpts: testutil.DummyAcctTestOptions{InvalidPinNumberEnter: true}},
+		{name: "retain fails", cardOpts: testutil.DummyCardTestOptions{ErrOnRetain: true}, acctOpts: testutil.DummyAcctTestOptions{InvalidPinNumberEnter: true}},
+		{name: "service errors", acctOpts: testutil.DummyAcctTestOptions{AccountIDs: []string{"test_account_1"}, ErrOnGetBalance: true, ErrOnMakeDeposit: true, ErrOnWithdraw: true, ErrOnChangePin: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+			ctrl := newTestController(t, Options{
+				CardSvc:      service.AdaptCard(testutil.NewDummyCardSvc(tc.cardOpts)),
+				AccountSvc:   service.AdaptAccount(testutil.NewDummyAccountSvc(tc.acctOpts), testutil.NewAccountAdapterOptions()),
+				Logger:       slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+				Clock:        clk,
+				EjectTimeout: time.Second,
+			})
+
+			var errs []error
+			collect := func(err error) {
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			ctx := context.Background()
+
+			collect(ctrl.InsertCard(ctx, model.Card{HolderName: "test user", Number: testPan, Expiry: "2512"}))
+			collect(ctrl.InsertCard(ctx, model.Card{HolderName: "test user", Number: "4111111111111112"}))
+			for i := 0; i < DefaultMaxPinAttempts; i++ {
+				_, err := ctrl.EnterPin(ctx, "2580")
+				collect(err)
+			}
+			collect(ctrl.ChangePin(ctx, "1357", "1357"))
+			collect(ctrl.SelectAccount(ctx, "test_account_1"))
+			_, err := ctrl.GetBalance(ctx, "test_account_1")
+			collect(err)
+			_, err = ctrl.MakeDeposit(ctx, "test_account_1", usd(10))
+			collect(err)
+			_, err = ctrl.MakeWithdrawl(ctx, "test_account_1", usd(10))
+			collect(err)
+			collect(ctrl.RemoveCard(ctx))
+
+			clk.Advance(time.Hour)
+			_, err = ctrl.CheckTimeout(ctx)
+			collect(err)
+			clk.Advance(time.Hour)
+			_, err = ctrl.CheckTimeout(ctx)
+			collect(err)
+
+			require.NotContains(t, logs.String(), testPan)
+			for _, err := range errs {
+				require.NotContains(t, err.Error(), testPan)
+				require.NotContains(t, fmt.Sprintf("%+v", err), testPan)
+			}
+			require.NotContains(t, fmt.Sprintf("%+v", ctrl.session.ViewCard()), testPan)
+		})
+	}
+}
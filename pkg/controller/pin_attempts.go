@@ -0,0 +1,49 @@
+package controller
+
+import "sync"
+
+// PinAttempts counts consecutive failed PIN entries per card number across
+// sessions. A single PinAttempts may be shared by several controllers.
+type PinAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func NewPinAttempts() *PinAttempts {
+	return &PinAttempts{failures: make(map[string]int)}
+}
+
+// Fail records a failed attempt for cardNumber and returns the new count.
+func (p *PinAttempts) Fail(cardNumber string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failures[cardNumber]++
+
+	return p.failures[cardNumber]
+}
+
+func (p *PinAttempts) Failures(cardNumber string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.failures[cardNumber]
+}
+
+// Reset forgets the failed attempts for cardNumber.
+func (p *PinAttempts) Reset(cardNumber string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.failures, cardNumber)
+}
+
+// PinResult describes the outcome of a PIN entry.
+type PinResult struct {
+	// TriesRemaining is the number of further attempts allowed before the
+	// card is retained.
+	TriesRemaining int
+	// CardRetained is set when the attempt limit was reached and the card
+	// was captured.
+	CardRetained bool
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"atm/pkg/service"
+	"context"
+)
+
+// ChangePin sets a new PIN for the inserted card. The current PIN must already
+// have been validated with EnterPin, and the customer enters the new PIN twice.
+func (ctrl *AtmController) ChangePin(ctx context.Context, newPin, confirmPin string) error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if err := ctrl.session.Require(atmcontext.Authenticated); err != nil {
+		return err
+	}
+	ctrl.touch()
+
+	if newPin != confirmPin {
+		return errorcode.ErrNewPinMismatch
+	}
+	if err := ctrl.pinPolicy.Validate(newPin); err != nil {
+		return err
+	}
+
+	card := *ctrl.session.ViewCard()
+	block, err := ctrl.pinCipher.Encrypt(newPin, card.Number)
+	if err != nil {
+		return err
+	}
+
+	err = ctrl.accountSvc.ChangePin(ctx, service.EncryptedPin{
+		PAN:      card.Number,
+		Format:   ctrl.pinCipher.Format(),
+		PinBlock: block,
+	})
+	if err != nil {
+		return errorcode.WrapRetryable(errorcode.PinChangeFail, err)
+	}
+
+	return nil
+}
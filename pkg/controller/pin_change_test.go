@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/model"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestChangePin(t *testing.T) {
+	var changedPin string
+	ctrl := newTestController(t, Options{
+		CardSvc: service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{})),
+		AccountSvc: service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			ChangedPinNumber: &changedPin,
+		}), testutil.NewAccountAdapterOptions()),
+	})
+	require.NoError(t, ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	}))
+
+	err := ctrl.ChangePin(context.Background(), "2580", "2580")
+	require.ErrorIs(t, err, errorcode.ErrPinNumberNotValidated)
+
+	_, _ = ctrl.EnterPin(context.Background(), "123123")
+
+	err = ctrl.ChangePin(context.Background(), "2580", "2580")
+	require.NoError(t, err)
+	require.Equal(t, "2580", changedPin)
+}
+
+func TestChangePinRejected(t *testing.T) {
+	var changedPin string
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		ChangedPinNumber: &changedPin,
+	}, Options{})
+
+	err := ctrl.ChangePin(context.Background(), "2580", "2581")
+	require.ErrorIs(t, err, errorcode.ErrNewPinMismatch)
+
+	err = ctrl.ChangePin(context.Background(), "1234", "1234")
+	require.ErrorIs(t, err, errorcode.ErrNewPinTooWeak)
+
+	err = ctrl.ChangePin(context.Background(), "0000", "0000")
+	require.ErrorIs(t, err, errorcode.ErrNewPinTooWeak)
+
+	err = ctrl.ChangePin(context.Background(), "258", "258")
+	require.ErrorIs(t, err, errorcode.ErrNewPinLength)
+
+	require.Empty(t, changedPin)
+}
+
+func TestChangePinError(t *testing.T) {
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		ErrOnChangePin: true,
+	}, Options{})
+
+	err := ctrl.ChangePin(context.Background(), "2580", "2580")
+	require.ErrorIs(t, err, errorcode.ErrPinChangeFail)
+	require.True(t, errorcode.IsRetryable(err))
+	require.True(t, ctrl.session.IsPinNumValidated())
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"context"
+	"time"
+)
+
+// TimeoutAction is what CheckTimeout did to the session.
+type TimeoutAction int
+
+const (
+	TimeoutNone TimeoutAction = iota
+	// TimeoutEjected means the session was idle too long, was invalidated
+	// and the card was ejected.
+	TimeoutEjected
+	// TimeoutRetained means an ejected card was not taken in time and was
+	// retained.
+	TimeoutRetained
+)
+
+func (ctrl *AtmController) touch() {
+	ctrl.session.Touch(ctrl.clock.Now())
+}
+
+// CheckTimeout enforces the idle and eject timeouts against the controller's
+// clock. It is meant to be called periodically by the terminal's event loop.
+// An operation in flight counts as activity, so CheckTimeout does nothing
+// while one is running.
+func (ctrl *AtmController) CheckTimeout(ctx context.Context) (TimeoutAction, error) {
+	if !ctrl.mu.TryLock() {
+		return TimeoutNone, nil
+	}
+	defer ctrl.mu.Unlock()
+
+	idleFor := ctrl.session.IdleFor(ctrl.clock.Now())
+
+	switch ctrl.session.State() {
+	case atmcontext.Idle:
+		return TimeoutNone, nil
+	case atmcontext.InTransaction:
+		// Only a cash deposit awaiting confirmation waits on the customer
+		// in this state.
+		if ctrl.escrow == nil {
+			return TimeoutNone, nil
+		}
+	case atmcontext.Ejecting:
+		if ctrl.ejectTimeout <= 0 || idleFor < ctrl.ejectTimeout {
+			return TimeoutNone, nil
+		}
+
+		ctrl.logger.Info("ejected card not taken, retaining")
+		err := ctrl.cardSvc.RetainCard(ctx)
+		_ = ctrl.session.Retain()
+		if err != nil {
+			return TimeoutRetained, errorcode.Wrap(errorcode.RetainCardFail, err)
+		}
+
+		return TimeoutRetained, nil
+	}
+
+	if idleFor < ctrl.idleTimeout {
+		return TimeoutNone, nil
+	}
+
+	ctrl.logger.Info("session idle timeout, ejecting card")
+	ctrl.abandonEscrow(ctx)
+	if err := ctrl.session.BeginEject(); err != nil {
+		return TimeoutNone, err
+	}
+	ctrl.touch()
+
+	if err := ctrl.cardSvc.RemoveCard(ctx); err != nil {
+		return TimeoutEjected, errorcode.Wrap(errorcode.RemoveCardFail, err)
+	}
+	if ctrl.ejectTimeout <= 0 {
+		return TimeoutEjected, ctrl.session.CompleteEject()
+	}
+
+	return TimeoutEjected, nil
+}
+
+// CardTaken tells the controller the customer has taken an ejected card.
+func (ctrl *AtmController) CardTaken() error {
+	if !ctrl.mu.TryLock() {
+		return errorcode.ErrOperationInProgress
+	}
+	defer ctrl.mu.Unlock()
+
+	if ctrl.session.State() != atmcontext.Ejecting {
+		return errorcode.ErrNoCardFound
+	}
+
+	return ctrl.session.CompleteEject()
+}
+
+// WatchTimeouts calls CheckTimeout every interval until ctx is done.
+func (ctrl *AtmController) WatchTimeouts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if action, err := ctrl.CheckTimeout(ctx); err != nil {
+				ctrl.logger.Warn("timeout check failed", "action", action, "err", err)
+			}
+		}
+	}
+}
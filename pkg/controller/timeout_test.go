@@ -0,0 +1,81 @@
+package controller
+
+import (
+	atmcontext "atm/pkg/context"
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{Clock: clk, IdleTimeout: time.Minute})
+
+	clk.Advance(59 * time.Second)
+	action, err := ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutNone, action)
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.NoError(t, err)
+
+	clk.Advance(59 * time.Second)
+	action, err = ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutNone, action)
+
+	clk.Advance(time.Second)
+	action, err = ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutEjected, action)
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+	require.Empty(t, ctrl.session.GetAccountID())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrNoCardFound)
+}
+
+func TestIdleTimeoutCardTaken(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{Clock: clk, IdleTimeout: time.Minute, EjectTimeout: 20 * time.Second})
+
+	clk.Advance(time.Minute)
+	action, err := ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutEjected, action)
+	require.Equal(t, atmcontext.Ejecting, ctrl.State())
+	require.False(t, ctrl.session.IsPinNumValidated())
+
+	_, err = ctrl.GetBalance(context.Background(), "test_account_1")
+	require.ErrorIs(t, err, errorcode.ErrCardEjecting)
+
+	clk.Advance(10 * time.Second)
+	action, err = ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutNone, action)
+
+	require.NoError(t, ctrl.CardTaken())
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+}
+
+func TestIdleTimeoutCardRetained(t *testing.T) {
+	clk := testutil.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{}, Options{Clock: clk, IdleTimeout: time.Minute, EjectTimeout: 20 * time.Second})
+
+	clk.Advance(time.Minute)
+	action, err := ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutEjected, action)
+
+	clk.Advance(20 * time.Second)
+	action, err = ctrl.CheckTimeout(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, TimeoutRetained, action)
+	require.Equal(t, atmcontext.Idle, ctrl.State())
+	require.False(t, ctrl.session.HasCardInserted())
+
+	require.ErrorIs(t, ctrl.CardTaken(), errorcode.ErrNoCardFound)
+}
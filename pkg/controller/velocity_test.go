@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/model"
+	"atm/pkg/velocity"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestVelocityPinEntryAcrossTerminals(t *testing.T) {
+	var alerts []velocity.Alert
+	checker := velocity.New(velocity.Config{
+		Rules: []velocity.Rule{{Operation: velocity.PinEntry, Subject: velocity.Card, Window: time.Hour, MaxCount: 2}},
+		OnAlert: func(a velocity.Alert) {
+			alerts = append(alerts, a)
+		},
+	})
+	m := newTestManager(t, testutil.DummyAcctTestOptions{}, Options{Velocity: checker})
+
+	for _, terminalID := range []string{"terminal-a", "terminal-b"} {
+		ctrl, err := m.Open(terminalID)
+		require.NoError(t, err)
+		require.NoError(t, ctrl.InsertCard(context.Background(), model.Card{
+			HolderName: "test user",
+			Number:     "4111111111111111",
+		}))
+		_, err = ctrl.EnterPin(context.Background(), "1234")
+		require.NoError(t, err)
+	}
+
+	ctrl, err := m.Open("terminal-c")
+	require.NoError(t, err)
+	require.NoError(t, ctrl.InsertCard(context.Background(), model.Card{
+		HolderName: "test user",
+		Number:     "4111111111111111",
+	}))
+	_, err = ctrl.EnterPin(context.Background(), "1234")
+	require.ErrorIs(t, err, errorcode.ErrVelocityExceeded)
+	require.False(t, ctrl.session.IsPinNumValidated())
+
+	require.Len(t, alerts, 1)
+	require.Equal(t, "terminal-c", alerts[0].Attempt.TerminalID)
+	require.Equal(t, 3, alerts[0].Count)
+}
+
+func TestVelocityWithdrawalsAndDeposits(t *testing.T) {
+	checker := velocity.New(velocity.Config{
+		Rules: []velocity.Rule{
+			{Operation: velocity.Withdrawal, Subject: velocity.Account, Window: time.Hour, MaxCount: 2},
+			{Operation: velocity.Deposit, Subject: velocity.Card, Window: time.Hour, MaxSum: usd(10000)},
+		},
+	})
+	m := newTestManager(t, testutil.DummyAcctTestOptions{
+		AccountIDs:    []string{"test_account_1"},
+		GetBalanceAmt: 100000,
+	}, Options{Velocity: checker})
+
+	ctrl, err := m.Open("terminal-a")
+	require.NoError(t, err)
+	selectAccount(t, ctrl, "4111111111111111", "test_account_1")
+
+	for range 2 {
+		_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(2000))
+		require.NoError(t, err)
+	}
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(2000))
+	require.ErrorIs(t, err, errorcode.ErrVelocityExceeded)
+
+	_, err = ctrl.MakeDeposit(context.Background(), "test_account_1", usd(8000))
+	require.NoError(t, err)
+	_, err = ctrl.MakeDeposit(context.Background(), "test_account_1", usd(3000))
+	require.ErrorIs(t, err, errorcode.ErrVelocityExceeded)
+}
+
+func TestVelocityCountsFailedAttempts(t *testing.T) {
+	checker := velocity.New(velocity.Config{
+		Rules: []velocity.Rule{{Operation: velocity.Withdrawal, Subject: velocity.Card, Window: time.Hour, MaxCount: 1}},
+	})
+	ctrl := newSelectedController(t, testutil.DummyAcctTestOptions{
+		GetBalanceAmt: 100000,
+		ErrOnWithdraw: true,
+	}, Options{Velocity: checker})
+
+	_, err := ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(2000))
+	require.ErrorIs(t, err, errorcode.ErrFailedToWithdraw)
+	_, err = ctrl.MakeWithdrawl(context.Background(), "test_account_1", usd(2000))
+	require.ErrorIs(t, err, errorcode.ErrVelocityExceeded)
+}
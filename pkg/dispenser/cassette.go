@@ -0,0 +1,45 @@
+package dispenser
+
+// Cassette is one note cassette in the dispenser.
+type Cassette struct {
+	ID string
+	// Denomination is the value of each note, in minor units of the
+	// dispenser's currency.
+	Denomination int64
+	Count        int
+	// LowThreshold is the note count at or below which the cassette is
+	// reported as Low.
+	LowThreshold int
+}
+
+type Status int
+
+const (
+	OK Status = iota
+	Low
+	Empty
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Low:
+		return "low"
+	case Empty:
+		return "empty"
+	default:
+		return "unknown"
+	}
+}
+
+func (c Cassette) Status() Status {
+	switch {
+	case c.Count <= 0:
+		return Empty
+	case c.Count <= c.LowThreshold:
+		return Low
+	default:
+		return OK
+	}
+}
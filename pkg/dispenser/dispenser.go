@@ -0,0 +1,109 @@
+// Package dispenser models the cash dispenser: its cassette inventory and
+// how a withdrawal amount is made up from the notes available.
+package dispenser
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Dispenser tracks the notes left in each cassette. It is safe for
+// concurrent use.
+type Dispenser struct {
+	mu        sync.Mutex
+	currency  model.Currency
+	cassettes []Cassette
+}
+
+// New returns a dispenser for notes in currency loaded with cassettes.
+// Cassette IDs must be unique and denominations positive.
+func New(currency model.Currency, cassettes []Cassette) (*Dispenser, error) {
+	seen := make(map[string]bool, len(cassettes))
+	for _, c := range cassettes {
+		if c.ID == "" || seen[c.ID] || c.Denomination <= 0 || c.Count < 0 {
+			return nil, errorcode.Wrap(errorcode.InvalidCassette, fmt.Errorf("%q", c.ID))
+		}
+		seen[c.ID] = true
+	}
+
+	return &Dispenser{currency: currency, cassettes: slices.Clone(cassettes)}, nil
+}
+
+func (d *Dispenser) Currency() model.Currency {
+	return d.currency
+}
+
+// Cassettes returns a snapshot of the cassette inventory.
+func (d *Dispenser) Cassettes() []Cassette {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.cassettes)
+}
+
+// Refill sets the note count of cassette id.
+func (d *Dispenser) Refill(id string, count int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := d.index(id)
+	if i < 0 {
+		return errorcode.Wrap(errorcode.UnknownCassette, fmt.Errorf("%q", id))
+	}
+	if count < 0 {
+		return errorcode.Wrap(errorcode.InvalidCassette, fmt.Errorf("%q: count %d", id, count))
+	}
+	d.cassettes[i].Count = count
+
+	return nil
+}
+
+// Mix works out which notes to dispense for amount without taking them.
+// It returns an error carrying errorcode.CashUnavailable if the cassettes
+// cannot make up amount exactly.
+func (d *Dispenser) Mix(amount model.Money, pref Preference) (Mix, error) {
+	if amount.Currency != d.currency {
+		return nil, errorcode.Wrap(errorcode.CurrencyMismatch, fmt.Errorf("%s and %s", amount.Currency, d.currency))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	mix, ok := mixNotes(amount.Amount, d.cassettes, pref)
+	if !ok {
+		return nil, errorcode.Wrap(errorcode.CashUnavailable, fmt.Errorf("%s", amount))
+	}
+
+	return mix, nil
+}
+
+// Dispense takes the notes in mix out of the cassettes. It fails without
+// changing the inventory if any cassette no longer holds enough notes.
+func (d *Dispenser) Dispense(mix Mix) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, p := range mix {
+		i := d.index(p.CassetteID)
+		if i < 0 {
+			return errorcode.Wrap(errorcode.UnknownCassette, fmt.Errorf("%q", p.CassetteID))
+		}
+		if d.cassettes[i].Count < p.Count {
+			return errorcode.Wrap(errorcode.CashUnavailable, fmt.Errorf("cassette %q has %d notes, want %d", p.CassetteID, d.cassettes[i].Count, p.Count))
+		}
+	}
+	for _, p := range mix {
+		d.cassettes[d.index(p.CassetteID)].Count -= p.Count
+	}
+
+	return nil
+}
+
+func (d *Dispenser) index(id string) int {
+	return slices.IndexFunc(d.cassettes, func(c Cassette) bool {
+		return c.ID == id
+	})
+}
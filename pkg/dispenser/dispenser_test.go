@@ -0,0 +1,198 @@
+package dispenser
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+func newTestDispenser(t *testing.T, cassettes ...Cassette) *Dispenser {
+	t.Helper()
+
+	d, err := New("USD", cassettes)
+	require.NoError(t, err)
+
+	return d
+}
+
+func TestNewInvalidCassettes(t *testing.T) {
+	for _, cassettes := range [][]Cassette{
+		{{ID: "", Denomination: 2000, Count: 1}},
+		{{ID: "a", Denomination: 0, Count: 1}},
+		{{ID: "a", Denomination: 2000, Count: -1}},
+		{{ID: "a", Denomination: 2000}, {ID: "a", Denomination: 5000}},
+	} {
+		_, err := New("USD", cassettes)
+		require.ErrorIs(t, err, errorcode.ErrInvalidCassette)
+	}
+}
+
+func TestMixMinimisesNotes(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 1000, Count: 100},
+		Cassette{ID: "b", Denomination: 2000, Count: 100},
+		Cassette{ID: "c", Denomination: 5000, Count: 100},
+	)
+
+	mix, err := d.Mix(usd(18000), Preference{})
+	require.NoError(t, err)
+	require.Equal(t, Mix{
+		{CassetteID: "a", Denomination: 1000, Count: 1},
+		{CassetteID: "b", Denomination: 2000, Count: 1},
+		{CassetteID: "c", Denomination: 5000, Count: 3},
+	}, mix)
+	require.Equal(t, int64(18000), mix.Total())
+	require.Equal(t, 5, mix.Notes())
+}
+
+func TestMixWhereGreedyFails(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 2000, Count: 100},
+		Cassette{ID: "b", Denomination: 5000, Count: 100},
+	)
+
+	mix, err := d.Mix(usd(6000), Preference{})
+	require.NoError(t, err)
+	require.Equal(t, Mix{{CassetteID: "a", Denomination: 2000, Count: 3}}, mix)
+
+	mix, err = d.Mix(usd(11000), Preference{})
+	require.NoError(t, err)
+	require.Equal(t, Mix{
+		{CassetteID: "a", Denomination: 2000, Count: 3},
+		{CassetteID: "b", Denomination: 5000, Count: 1},
+	}, mix)
+}
+
+func TestMixPreservesLowDenominations(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 1000, Count: 100},
+		Cassette{ID: "b", Denomination: 2000, Count: 100},
+		Cassette{ID: "c", Denomination: 3000, Count: 100},
+	)
+
+	// 40 can be 20+20 or 30+10; both are two notes, so keep the tens.
+	mix, err := d.Mix(usd(4000), Preference{})
+	require.NoError(t, err)
+	require.Equal(t, Mix{
+		{CassetteID: "a", Denomination: 1000, Count: 1},
+		{CassetteID: "c", Denomination: 3000, Count: 1},
+	}, mix)
+}
+
+func TestMixPreference(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 2000, Count: 4},
+		Cassette{ID: "b", Denomination: 5000, Count: 100},
+	)
+
+	mix, err := d.Mix(usd(20000), Preference{Denomination: 2000})
+	require.NoError(t, err)
+	require.Equal(t, Mix{{CassetteID: "b", Denomination: 5000, Count: 4}}, mix)
+
+	mix, err = d.Mix(usd(18000), Preference{Denomination: 2000})
+	require.NoError(t, err)
+	require.Equal(t, Mix{
+		{CassetteID: "a", Denomination: 2000, Count: 4},
+		{CassetteID: "b", Denomination: 5000, Count: 2},
+	}, mix)
+
+	// An unavailable preference is ignored.
+	mix, err = d.Mix(usd(10000), Preference{Denomination: 10000})
+	require.NoError(t, err)
+	require.Equal(t, Mix{{CassetteID: "b", Denomination: 5000, Count: 2}}, mix)
+}
+
+func TestMixCashUnavailable(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 2000, Count: 2},
+		Cassette{ID: "b", Denomination: 5000, Count: 1},
+	)
+
+	for _, amount := range []int64{0, 1000, 3000, 10000, 9500} {
+		_, err := d.Mix(usd(amount), Preference{})
+		require.ErrorIs(t, err, errorcode.ErrCashUnavailable, amount)
+	}
+
+	_, err := d.Mix(model.NewMoney(2000, "EUR"), Preference{})
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+}
+
+func TestMixLargeInventory(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 10000, Count: 2000},
+		Cassette{ID: "b", Denomination: 5000, Count: 2000},
+		Cassette{ID: "c", Denomination: 2000, Count: 2000},
+		Cassette{ID: "d", Denomination: 1000, Count: 2000},
+	)
+
+	start := time.Now()
+	for _, amount := range []int64{1234500, 10000001, 36000100, 1000, 3000} {
+		_, err := d.Mix(usd(amount), Preference{})
+		if amount <= 3000 {
+			require.NoError(t, err, amount)
+			continue
+		}
+		require.ErrorIs(t, err, errorcode.ErrCashUnavailable, amount)
+	}
+
+	mix, err := d.Mix(usd(35999000), Preference{Denomination: 2000})
+	require.NoError(t, err)
+	require.Equal(t, int64(35999000), mix.Total())
+	require.Equal(t, Pick{CassetteID: "c", Denomination: 2000, Count: 2000}, mix[2])
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestMixUnreachable(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 5000, Count: 2000},
+		Cassette{ID: "b", Denomination: 2000, Count: 1},
+	)
+
+	// Every amount is a multiple of 10.00 and within the cash held, so only
+	// the search can rule them out.
+	start := time.Now()
+	for _, amount := range []int64{1000, 3000, 9999000} {
+		_, err := d.Mix(usd(amount), Preference{})
+		require.ErrorIs(t, err, errorcode.ErrCashUnavailable, amount)
+	}
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestDispense(t *testing.T) {
+	d := newTestDispenser(t,
+		Cassette{ID: "a", Denomination: 2000, Count: 3, LowThreshold: 2},
+		Cassette{ID: "b", Denomination: 2000, Count: 5, LowThreshold: 2},
+		Cassette{ID: "c", Denomination: 5000, Count: 1},
+	)
+
+	mix, err := d.Mix(usd(21000), Preference{})
+	require.NoError(t, err)
+	require.Equal(t, Mix{
+		{CassetteID: "a", Denomination: 2000, Count: 3},
+		{CassetteID: "b", Denomination: 2000, Count: 5},
+		{CassetteID: "c", Denomination: 5000, Count: 1},
+	}, mix)
+
+	require.NoError(t, d.Dispense(mix))
+	cassettes := d.Cassettes()
+	require.Equal(t, Empty, cassettes[0].Status())
+	require.Equal(t, Empty, cassettes[1].Status())
+	require.Equal(t, Empty, cassettes[2].Status())
+
+	require.ErrorIs(t, d.Dispense(mix), errorcode.ErrCashUnavailable)
+
+	_, err = d.Mix(usd(2000), Preference{})
+	require.ErrorIs(t, err, errorcode.ErrCashUnavailable)
+
+	require.NoError(t, d.Refill("b", 2))
+	require.Equal(t, Low, d.Cassettes()[1].Status())
+	require.NoError(t, d.Refill("b", 10))
+	require.Equal(t, OK, d.Cassettes()[1].Status())
+	require.ErrorIs(t, d.Refill("z", 10), errorcode.ErrUnknownCassette)
+}
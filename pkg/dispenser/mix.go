@@ -0,0 +1,207 @@
+package dispenser
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Preference is the customer's choice of notes for a withdrawal. The zero
+// Preference dispenses as few notes as possible.
+type Preference struct {
+	// Denomination, if set, is used for as much of the amount as the
+	// cassettes allow; the rest is made up with as few notes as possible.
+	Denomination int64
+}
+
+// Pick is a number of notes taken from one cassette.
+type Pick struct {
+	CassetteID   string
+	Denomination int64
+	Count        int
+}
+
+// Mix is the set of notes making up one withdrawal.
+type Mix []Pick
+
+// Total returns the value of the mix in minor units.
+func (m Mix) Total() int64 {
+	var total int64
+	for _, p := range m {
+		total += p.Denomination * int64(p.Count)
+	}
+
+	return total
+}
+
+// Notes returns the number of notes in the mix.
+func (m Mix) Notes() int {
+	var notes int
+	for _, p := range m {
+		notes += p.Count
+	}
+
+	return notes
+}
+
+// mixNotes finds the notes making up amount. It honours pref first, then
+// minimises the number of notes, and among mixes with as few notes prefers
+// the one using the most high-value notes so that low denominations are
+// kept for amounts that need them.
+//
+// The search is a dynamic programme over amounts in units of the greatest
+// common divisor of the notes, so its cost is bounded by the cash held in
+// the cassettes rather than by the number of possible mixes.
+func mixNotes(amount int64, cassettes []Cassette, pref Preference) (Mix, bool) {
+	if amount <= 0 {
+		return nil, false
+	}
+
+	available := make(map[int64]int)
+	for _, c := range cassettes {
+		if c.Count > 0 {
+			available[c.Denomination] += c.Count
+		}
+	}
+	if len(available) == 0 {
+		return nil, false
+	}
+
+	values := make([]int64, 0, len(available))
+	var unit, held int64
+	for v, n := range available {
+		values = append(values, v)
+		unit = gcd(unit, v)
+		held += v * int64(n)
+	}
+	if amount%unit != 0 || amount > held {
+		return nil, false
+	}
+	slices.SortFunc(values, func(a, b int64) int {
+		return cmp.Compare(b, a)
+	})
+
+	preferring := false
+	if i := slices.Index(values, pref.Denomination); i > 0 {
+		values = append(append([]int64{pref.Denomination}, values[:i]...), values[i+1:]...)
+		preferring = true
+	} else if i == 0 {
+		preferring = true
+	}
+
+	// fewest[i][r] is the fewest notes from values[i:] making up r units,
+	// or unreachable.
+	target := int(amount / unit)
+	fewest := make([][]int, len(values)+1)
+	fewest[len(values)] = make([]int, target+1)
+	for r := 1; r <= target; r++ {
+		fewest[len(values)][r] = unreachable
+	}
+	for i := len(values) - 1; i >= 0; i-- {
+		fewest[i] = addNotes(fewest[i+1], int(values[i]/unit), available[values[i]])
+	}
+	if fewest[0][target] == unreachable {
+		return nil, false
+	}
+
+	// Walk back from the largest count of each note that still leads to an
+	// optimal mix. The preferred note only needs the rest to be reachable.
+	counts := make([]int, len(values))
+	r := target
+	for i, v := range values {
+		step := int(v / unit)
+		for c := min(available[v], r/step); c >= 0; c-- {
+			rest := fewest[i+1][r-c*step]
+			if rest == unreachable {
+				continue
+			}
+			if (preferring && i == 0) || rest+c == fewest[i][r] {
+				counts[i] = c
+				r -= c * step
+				break
+			}
+		}
+	}
+
+	return allocate(values, counts, cassettes), true
+}
+
+const unreachable = -1
+
+// addNotes returns, for each amount r, the fewest notes making up r from at
+// most n notes of step units plus the notes next needs for the rest. It
+// keeps a sliding window minimum per residue modulo step, so it runs in
+// time linear in len(next).
+func addNotes(next []int, step, n int) []int {
+	type candidate struct{ j, notes int }
+
+	cur := make([]int, len(next))
+	window := make([]candidate, 0, len(next)/step+1)
+	for rem := 0; rem < step && rem < len(next); rem++ {
+		window = window[:0]
+		head := 0
+		for j := 0; rem+j*step < len(next); j++ {
+			r := rem + j*step
+			if next[r] != unreachable {
+				c := candidate{j: j, notes: next[r] - j}
+				for len(window) > head && window[len(window)-1].notes >= c.notes {
+					window = window[:len(window)-1]
+				}
+				window = append(window, c)
+			}
+			for len(window) > head && window[head].j < j-n {
+				head++
+			}
+			if len(window) == head {
+				cur[r] = unreachable
+			} else {
+				cur[r] = window[head].notes + j
+			}
+		}
+	}
+
+	return cur
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// allocate spreads the note counts per denomination over the cassettes,
+// drawing from the fullest cassette of each denomination first.
+func allocate(values []int64, counts []int, cassettes []Cassette) Mix {
+	order := make([]int, len(cassettes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(cassettes[b].Count, cassettes[a].Count)
+	})
+
+	taken := make([]int, len(cassettes))
+	for vi, v := range values {
+		need := counts[vi]
+		for _, ci := range order {
+			if need == 0 {
+				break
+			}
+			if cassettes[ci].Denomination != v || cassettes[ci].Count <= 0 {
+				continue
+			}
+			taken[ci] = min(need, cassettes[ci].Count)
+			need -= taken[ci]
+		}
+	}
+
+	var mix Mix
+	for ci, n := range taken {
+		if n > 0 {
+			mix = append(mix, Pick{CassetteID: cassettes[ci].ID, Denomination: cassettes[ci].Denomination, Count: n})
+		}
+	}
+
+	return mix
+}
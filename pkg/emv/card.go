@@ -0,0 +1,137 @@
+// Package emv extracts card data from the BER-TLV records read off an EMV
+// chip card.
+package emv
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"atm/pkg/tlv"
+	"fmt"
+	"strings"
+)
+
+// ARQCTags are the data objects an issuer needs to validate an ARQC. They
+// are carried in CardData.ChipData to the account service.
+var ARQCTags = []tlv.Tag{
+	tlv.TagAmountAuthorised,
+	tlv.TagAmountOther,
+	tlv.TagTerminalCountryCode,
+	tlv.TagTVR,
+	tlv.TagTransactionCurrencyCode,
+	tlv.TagTransactionDate,
+	tlv.TagTransactionType,
+	tlv.TagUnpredictableNumber,
+	tlv.TagAIP,
+	tlv.TagATC,
+	tlv.TagIssuerApplicationData,
+	tlv.TagApplicationCryptogram,
+	tlv.TagCryptogramInformation,
+	tlv.TagCVMResults,
+	tlv.TagTerminalCapabilities,
+	tlv.TagTerminalType,
+	tlv.TagDFName,
+	tlv.TagPANSequenceNumber,
+}
+
+// CardData is a card read from its chip, together with the ARQC data
+// objects the account service needs alongside it.
+type CardData struct {
+	Card     model.Card
+	ChipData []byte
+}
+
+// CardFromTLV builds a model.Card from chip data. The PAN comes from tag 5A,
+// or from the Track 2 Equivalent Data (57) if 5A is absent; when both are
+// present they must agree. Expiry and service code fall back to tag 57 too.
+func CardFromTLV(data []byte) (CardData, error) {
+	objs, err := tlv.Decode(data)
+	if err != nil {
+		return CardData{}, err
+	}
+
+	var card model.Card
+	if obj, ok := tlv.Find(objs, tlv.TagTrack2Equivalent); ok {
+		card, err = parseTrack2Equivalent(obj.Value)
+		if err != nil {
+			return CardData{}, err
+		}
+	}
+
+	if obj, ok := tlv.Find(objs, tlv.TagPAN); ok {
+		pan := bcdDigits(obj.Value)
+		if card.Number != "" && card.Number != pan {
+			return CardData{}, chipErr("pan %s does not match track 2 equivalent data", tlv.TagPAN)
+		}
+		card.Number = pan
+	}
+	if card.Number == "" {
+		return CardData{}, chipErr("no pan")
+	}
+
+	if obj, ok := tlv.Find(objs, tlv.TagCardholderName); ok {
+		card.HolderName = model.HolderNameFromTrack(string(obj.Value))
+	}
+	if obj, ok := tlv.Find(objs, tlv.TagExpirationDate); ok {
+		date := bcdDigits(obj.Value)
+		if len(date) != 6 {
+			return CardData{}, chipErr("expiration date %q", date)
+		}
+		card.Expiry = date[:4]
+	}
+	if obj, ok := tlv.Find(objs, tlv.TagServiceCode); ok {
+		code := bcdDigits(obj.Value)
+		if len(code) != 4 || code[0] != '0' {
+			return CardData{}, chipErr("service code %q", code)
+		}
+		card.ServiceCode = code[1:]
+	}
+
+	return CardData{Card: card, ChipData: ARQCData(objs)}, nil
+}
+
+// ARQCData re-encodes the objects in objs whose tags are in ARQCTags, in
+// ARQCTags order. It returns nil if none are present.
+func ARQCData(objs []tlv.TLV) []byte {
+	var selected []tlv.TLV
+	for _, tag := range ARQCTags {
+		if obj, ok := tlv.Find(objs, tag); ok {
+			selected = append(selected, obj)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	return tlv.Encode(selected...)
+}
+
+// parseTrack2Equivalent decodes tag 57: PAN, separator D, YYMM, service code
+// and discretionary data, packed as BCD and padded with F.
+func parseTrack2Equivalent(value []byte) (model.Card, error) {
+	nibbles := fmt.Sprintf("%X", value)
+	nibbles = strings.TrimRight(nibbles, "F")
+
+	// The data holds the PAN, so errors only describe its shape.
+	pan, rest, ok := strings.Cut(nibbles, "D")
+	if !ok {
+		return model.Card{}, chipErr("track 2 equivalent data has no separator")
+	}
+	if len(rest) < 7 {
+		return model.Card{}, chipErr("track 2 equivalent data has %d digits after the pan, want at least 7", len(rest))
+	}
+
+	return model.Card{
+		Number:      pan,
+		Expiry:      rest[:4],
+		ServiceCode: rest[4:7],
+	}, nil
+}
+
+// bcdDigits returns the BCD digits in b with trailing F padding removed.
+func bcdDigits(b []byte) string {
+	return strings.TrimRight(fmt.Sprintf("%X", b), "F")
+}
+
+func chipErr(format string, args ...any) error {
+	return errorcode.Wrap(errorcode.ChipDataInvalid, fmt.Errorf(format, args...))
+}
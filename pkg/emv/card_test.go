@@ -0,0 +1,82 @@
+package emv
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"atm/pkg/tlv"
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestCardFromTLV(t *testing.T) {
+	data := tlv.Encode(
+		tlv.TLV{Tag: tlv.TagRecordTemplate, Children: []tlv.TLV{
+			{Tag: tlv.TagTrack2Equivalent, Value: mustHex(t, "4111111111111111D25122011234567F")},
+			{Tag: tlv.TagPAN, Value: mustHex(t, "4111111111111111")},
+			{Tag: tlv.TagCardholderName, Value: []byte("DOE/JOHN                  ")},
+			{Tag: tlv.TagExpirationDate, Value: mustHex(t, "251231")},
+			{Tag: tlv.TagServiceCode, Value: mustHex(t, "0201")},
+		}},
+		tlv.TLV{Tag: tlv.TagApplicationCryptogram, Value: mustHex(t, "1122334455667788")},
+		tlv.TLV{Tag: tlv.TagATC, Value: mustHex(t, "0042")},
+	)
+
+	cardData, err := CardFromTLV(data)
+	require.NoError(t, err)
+	card := cardData.Card
+	require.Equal(t, "4111111111111111", card.Number)
+	require.Equal(t, "JOHN DOE", card.HolderName)
+	require.Equal(t, "2512", card.Expiry)
+	require.Equal(t, "201", card.ServiceCode)
+
+	arqc, err := tlv.Decode(cardData.ChipData)
+	require.NoError(t, err)
+	require.Equal(t, []tlv.TLV{
+		{Tag: tlv.TagATC, Value: mustHex(t, "0042")},
+		{Tag: tlv.TagApplicationCryptogram, Value: mustHex(t, "1122334455667788")},
+	}, arqc)
+}
+
+func TestCardFromTrack2Equivalent(t *testing.T) {
+	data := tlv.Encode(tlv.TLV{Tag: tlv.TagTrack2Equivalent, Value: mustHex(t, "5500000000000004D2601101")})
+
+	cardData, err := CardFromTLV(data)
+	require.NoError(t, err)
+	require.Equal(t, CardData{Card: model.Card{
+		Number:      "5500000000000004",
+		Expiry:      "2601",
+		ServiceCode: "101",
+	}}, cardData)
+}
+
+func TestCardFromTLVErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		tlv.Encode(tlv.TLV{Tag: tlv.TagCardholderName, Value: []byte("DOE/JOHN")}),
+		tlv.Encode(
+			tlv.TLV{Tag: tlv.TagTrack2Equivalent, Value: mustHex(t, "4111111111111111D2512201")},
+			tlv.TLV{Tag: tlv.TagPAN, Value: mustHex(t, "5500000000000004")},
+		),
+		tlv.Encode(tlv.TLV{Tag: tlv.TagTrack2Equivalent, Value: mustHex(t, "4111111111111111")}),
+		tlv.Encode(
+			tlv.TLV{Tag: tlv.TagPAN, Value: mustHex(t, "4111111111111111")},
+			tlv.TLV{Tag: tlv.TagExpirationDate, Value: mustHex(t, "2512")},
+		),
+	} {
+		_, err := CardFromTLV(data)
+		require.ErrorIs(t, err, errorcode.ErrChipDataInvalid, hex.EncodeToString(data))
+		require.NotContains(t, err.Error(), "4111111111111111")
+	}
+
+	_, err := CardFromTLV(mustHex(t, "5A08"))
+	require.ErrorIs(t, err, errorcode.ErrTLVDecodeFail)
+}
@@ -1,13 +1,56 @@
 package errorcode
 
 const (
+	NilAccountService = "account service is nil"
+	NilCardService    = "card service is nil"
+
+	IllegalTransition     = "illegal session state transition"
+	CardEjecting          = "card is being ejected"
+	TransactionInProgress = "transaction in progress"
+	Unsupported           = "operation not supported by service"
+	OperationInProgress   = "another operation is in progress"
+
+	EmptyTerminalID    = "terminal id is empty"
+	SessionAlreadyOpen = "session already open for terminal"
+	SessionNotFound    = "no session for terminal"
+	SessionHasCard     = "session still holds a card"
+	SharedDevices      = "cash devices must be given per terminal"
+
 	NoCardFound    = "no card found"
 	InsertCardFail = "failed to insert card"
 	RemoveCardFail = "failed to remove card"
+	RetainCardFail = "failed to retain card"
+	CardRetained   = "card retained"
+
+	InvalidCardNumber  = "invalid card number"
+	InvalidCardLength  = "invalid card number length"
+	CardLuhnCheckFail  = "card number fails luhn check"
+	InvalidCardExpiry  = "invalid card expiry"
+	CardExpired        = "card expired"
+	InvalidServiceCode = "invalid service code"
+
+	TrackParseFail   = "failed to parse track data"
+	TrackLRCMismatch = "track lrc mismatch"
+	TrackMismatch    = "track 1 and track 2 do not match"
+
+	TLVDecodeFail   = "failed to decode tlv data"
+	ChipDataInvalid = "invalid chip card data"
 
 	PinNumberCheckFail    = "failed to check card pin number"
 	InvalidPinNumber      = "invalid pin number"
 	PinNumberNotValidated = "pin number not validated"
+	NilPinCipher          = "pin cipher is nil"
+
+	InvalidPinFormat          = "pin must be 4 to 12 digits"
+	InvalidPan                = "invalid primary account number"
+	InvalidPinKey             = "invalid pin key"
+	InvalidPinBlock           = "invalid pin block"
+	UnsupportedPinBlockFormat = "unsupported pin block format"
+
+	NewPinMismatch = "new pin entries do not match"
+	NewPinLength   = "new pin has invalid length"
+	NewPinTooWeak  = "new pin is too easy to guess"
+	PinChangeFail  = "failed to change pin"
 
 	GetAccountIDsFail       = "failed to get account ids"
 	NoMatchingAccountID     = "no matching account id"
@@ -17,8 +60,150 @@ const (
 
 	FailedToGetBalance = "failed to get balance"
 
+	UnknownCurrency  = "unknown currency"
+	CurrencyMismatch = "currency mismatch"
+	AmountOverflow   = "amount overflow"
+
+	NonPositiveAmount    = "amount must be positive"
+	AmountNotDispensable = "amount cannot be dispensed in available denominations"
+	AmountAboveMaximum   = "amount is above the per-transaction maximum"
+
+	InvalidCassette = "invalid cassette"
+	UnknownCassette = "unknown cassette"
+	CashUnavailable = "cash unavailable for amount"
+	DispenseFail    = "failed to dispense cash"
+	PartialDispense = "cash only partly dispensed"
+	CashRetracted   = "cash not taken and retracted"
+	PresentUnknown  = "cash present outcome unknown"
+	ReversalFail    = "failed to reverse withdrawal"
+	NilDispenser    = "cash dispenser service needs a dispenser"
+
+	AcceptNotesFail  = "failed to accept notes"
+	NoNotesAccepted  = "no notes accepted"
+	StackNotesFail   = "failed to stack notes"
+	ReturnNotesFail  = "failed to return notes"
+	NoDepositPending = "no cash deposit awaiting confirmation"
+	NotesStacked     = "notes already stacked"
+
+	MICRParseFail         = "failed to parse micr line"
+	RoutingCheckFail      = "routing number check digit mismatch"
+	ChequeAmountMismatch  = "declared amount does not match encoded amount"
+	FailedToDepositCheque = "failed to deposit cheque"
+
+	WithdrawalLimitExceeded = "withdrawal limit exceeded"
+	VelocityExceeded        = "transaction velocity exceeded"
+
+	NoFeeQuoted       = "no fee quoted"
+	FeeNotAccepted    = "fee not accepted"
+	FailedToChargeFee = "failed to charge fee"
+
 	FailedToMakeDeposit = "failed to make deposit"
 
 	IsOverdraw       = "is overdraw"
 	FailedToWithdraw = "failed to withdraw"
+	DebitUnknown     = "withdrawal debit outcome unknown"
+)
+
+var (
+	ErrNilAccountService = New(NilAccountService)
+	ErrNilCardService    = New(NilCardService)
+
+	ErrIllegalTransition     = New(IllegalTransition)
+	ErrCardEjecting          = New(CardEjecting)
+	ErrTransactionInProgress = New(TransactionInProgress)
+	ErrUnsupported           = New(Unsupported)
+	ErrOperationInProgress   = New(OperationInProgress)
+
+	ErrEmptyTerminalID    = New(EmptyTerminalID)
+	ErrSessionAlreadyOpen = New(SessionAlreadyOpen)
+	ErrSessionNotFound    = New(SessionNotFound)
+	ErrSessionHasCard     = New(SessionHasCard)
+	ErrSharedDevices      = New(SharedDevices)
+
+	ErrNoCardFound    = New(NoCardFound)
+	ErrInsertCardFail = New(InsertCardFail)
+	ErrRemoveCardFail = New(RemoveCardFail)
+	ErrRetainCardFail = New(RetainCardFail)
+	ErrCardRetained   = New(CardRetained)
+
+	ErrInvalidCardNumber  = New(InvalidCardNumber)
+	ErrInvalidCardLength  = New(InvalidCardLength)
+	ErrCardLuhnCheckFail  = New(CardLuhnCheckFail)
+	ErrInvalidCardExpiry  = New(InvalidCardExpiry)
+	ErrCardExpired        = New(CardExpired)
+	ErrInvalidServiceCode = New(InvalidServiceCode)
+
+	ErrTrackParseFail   = New(TrackParseFail)
+	ErrTrackLRCMismatch = New(TrackLRCMismatch)
+	ErrTrackMismatch    = New(TrackMismatch)
+
+	ErrTLVDecodeFail   = New(TLVDecodeFail)
+	ErrChipDataInvalid = New(ChipDataInvalid)
+
+	ErrPinNumberCheckFail    = New(PinNumberCheckFail)
+	ErrInvalidPinNumber      = New(InvalidPinNumber)
+	ErrPinNumberNotValidated = New(PinNumberNotValidated)
+	ErrNilPinCipher          = New(NilPinCipher)
+
+	ErrInvalidPinFormat          = New(InvalidPinFormat)
+	ErrInvalidPan                = New(InvalidPan)
+	ErrInvalidPinKey             = New(InvalidPinKey)
+	ErrInvalidPinBlock           = New(InvalidPinBlock)
+	ErrUnsupportedPinBlockFormat = New(UnsupportedPinBlockFormat)
+
+	ErrNewPinMismatch = New(NewPinMismatch)
+	ErrNewPinLength   = New(NewPinLength)
+	ErrNewPinTooWeak  = New(NewPinTooWeak)
+	ErrPinChangeFail  = New(PinChangeFail)
+
+	ErrGetAccountIDsFail       = New(GetAccountIDsFail)
+	ErrNoMatchingAccountID     = New(NoMatchingAccountID)
+	ErrFailedToSelectAccountID = New(FailedToSelectAccountID)
+	ErrNoAccountSelected       = New(NoAccountSelected)
+	ErrAccountIDMismatch       = New(AccountIDMismatch)
+
+	ErrFailedToGetBalance = New(FailedToGetBalance)
+
+	ErrUnknownCurrency  = New(UnknownCurrency)
+	ErrCurrencyMismatch = New(CurrencyMismatch)
+	ErrAmountOverflow   = New(AmountOverflow)
+
+	ErrNonPositiveAmount    = New(NonPositiveAmount)
+	ErrAmountNotDispensable = New(AmountNotDispensable)
+	ErrAmountAboveMaximum   = New(AmountAboveMaximum)
+
+	ErrInvalidCassette = New(InvalidCassette)
+	ErrUnknownCassette = New(UnknownCassette)
+	ErrCashUnavailable = New(CashUnavailable)
+	ErrDispenseFail    = New(DispenseFail)
+	ErrPartialDispense = New(PartialDispense)
+	ErrCashRetracted   = New(CashRetracted)
+	ErrPresentUnknown  = New(PresentUnknown)
+	ErrReversalFail    = New(ReversalFail)
+	ErrNilDispenser    = New(NilDispenser)
+
+	ErrAcceptNotesFail  = New(AcceptNotesFail)
+	ErrNoNotesAccepted  = New(NoNotesAccepted)
+	ErrStackNotesFail   = New(StackNotesFail)
+	ErrReturnNotesFail  = New(ReturnNotesFail)
+	ErrNoDepositPending = New(NoDepositPending)
+	ErrNotesStacked     = New(NotesStacked)
+
+	ErrMICRParseFail         = New(MICRParseFail)
+	ErrRoutingCheckFail      = New(RoutingCheckFail)
+	ErrChequeAmountMismatch  = New(ChequeAmountMismatch)
+	ErrFailedToDepositCheque = New(FailedToDepositCheque)
+
+	ErrWithdrawalLimitExceeded = New(WithdrawalLimitExceeded)
+	ErrVelocityExceeded        = New(VelocityExceeded)
+
+	ErrNoFeeQuoted       = New(NoFeeQuoted)
+	ErrFeeNotAccepted    = New(FeeNotAccepted)
+	ErrFailedToChargeFee = New(FailedToChargeFee)
+
+	ErrFailedToMakeDeposit = New(FailedToMakeDeposit)
+
+	ErrIsOverdraw       = New(IsOverdraw)
+	ErrFailedToWithdraw = New(FailedToWithdraw)
+	ErrDebitUnknown     = New(DebitUnknown)
 )
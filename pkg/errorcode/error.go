@@ -0,0 +1,82 @@
+package errorcode
+
+import "errors"
+
+// Error is the error type returned by the atm packages. Code is one of the
+// constants in this package, Err is the underlying cause (if any) and
+// Retryable reports whether the same operation may succeed if attempted again.
+type Error struct {
+	Code      string
+	Err       error
+	Retryable bool
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code, so that
+// errors.Is(err, errorcode.ErrNoCardFound) matches regardless of the cause.
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) {
+		return false
+	}
+
+	return t.Code == e.Code
+}
+
+// New returns an error with the given code and no cause.
+func New(code string) error {
+	return &Error{Code: code}
+}
+
+// Wrap returns an error with the given code wrapping cause.
+func Wrap(code string, cause error) error {
+	return &Error{Code: code, Err: cause}
+}
+
+// WrapRetryable is like Wrap but marks the error as retryable, unless cause
+// is ErrUnsupported: a service that cannot do something will not be able to
+// on a second attempt either.
+func WrapRetryable(code string, cause error) error {
+	return &Error{Code: code, Err: cause, Retryable: !errors.Is(cause, ErrUnsupported)}
+}
+
+// Code returns the code of the first *Error in err's chain, or "" if there is none.
+func Code(err error) string {
+	var e *Error
+	if !errors.As(err, &e) {
+		return ""
+	}
+
+	return e.Code
+}
+
+// IsRetryable reports whether any *Error in err's chain is marked retryable.
+// An error that matches ErrUnsupported is never retryable.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrUnsupported) {
+		return false
+	}
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			return false
+		}
+		if e.Retryable {
+			return true
+		}
+		err = e.Err
+	}
+
+	return false
+}
@@ -0,0 +1,42 @@
+package errorcode
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	cause := errors.New("host unreachable")
+	err := Wrap(FailedToGetBalance, cause)
+
+	require.ErrorIs(t, err, ErrFailedToGetBalance)
+	require.ErrorIs(t, err, cause)
+	require.NotErrorIs(t, err, ErrFailedToWithdraw)
+	require.EqualError(t, err, "failed to get balance: host unreachable")
+}
+
+func TestErrorAs(t *testing.T) {
+	err := fmt.Errorf("outer: %w", WrapRetryable(FailedToWithdraw, errors.New("timeout")))
+
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, FailedToWithdraw, e.Code)
+	require.Equal(t, FailedToWithdraw, Code(err))
+	require.True(t, IsRetryable(err))
+}
+
+func TestNotRetryable(t *testing.T) {
+	require.False(t, IsRetryable(ErrNoCardFound))
+	require.False(t, IsRetryable(Wrap(InsertCardFail, errors.New("jam"))))
+	require.False(t, IsRetryable(errors.New("plain")))
+	require.Equal(t, "", Code(errors.New("plain")))
+}
+
+func TestUnsupportedNotRetryable(t *testing.T) {
+	require.False(t, IsRetryable(ErrUnsupported))
+	require.False(t, IsRetryable(WrapRetryable(FailedToWithdraw, ErrUnsupported)))
+	require.False(t, IsRetryable(fmt.Errorf("outer: %w", WrapRetryable(FailedToWithdraw, ErrUnsupported))))
+	require.False(t, IsRetryable(&Error{Code: FailedToWithdraw, Err: ErrUnsupported, Retryable: true}))
+}
@@ -0,0 +1,113 @@
+// Package fees works out the surcharge for a transaction from a fee
+// schedule that distinguishes the terminal bank's own cards from foreign
+// ones.
+package fees
+
+import (
+	"atm/pkg/model"
+	"strings"
+)
+
+type Transaction string
+
+const (
+	Withdrawal     Transaction = "withdrawal"
+	BalanceInquiry Transaction = "balance inquiry"
+)
+
+// Rule is the fee for one kind of transaction: a fixed part plus a
+// percentage of the amount. If Tiers is set, the first tier whose UpTo
+// covers the amount is used instead.
+type Rule struct {
+	Fixed model.Money
+	// BasisPoints is the percentage part in hundredths of a percent.
+	BasisPoints int64
+	Tiers       []Tier
+}
+
+// Tier is a Rule for amounts up to and including UpTo. A zero UpTo covers
+// every amount.
+type Tier struct {
+	UpTo        model.Money
+	Fixed       model.Money
+	BasisPoints int64
+}
+
+type Schedule struct {
+	OnUs    map[Transaction]Rule
+	Foreign map[Transaction]Rule
+	// OnUsBINs are the card number prefixes issued by the terminal's bank.
+	OnUsBINs []string
+}
+
+// IsOnUs reports whether card was issued by the terminal's bank.
+func (s Schedule) IsOnUs(card model.Card) bool {
+	for _, bin := range s.OnUsBINs {
+		if bin != "" && strings.HasPrefix(card.Number, bin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fee returns the fee for txn of amount with card. amount is zero for
+// balance inquiries. A zero result means no fee.
+func (s Schedule) Fee(card model.Card, txn Transaction, amount model.Money) (model.Money, error) {
+	rules := s.Foreign
+	if s.IsOnUs(card) {
+		rules = s.OnUs
+	}
+	rule, ok := rules[txn]
+	if !ok {
+		return model.Money{}, nil
+	}
+
+	fixed, bp := rule.Fixed, rule.BasisPoints
+	for _, tier := range rule.Tiers {
+		if tier.UpTo.IsZero() {
+			fixed, bp = tier.Fixed, tier.BasisPoints
+			break
+		}
+		// A balance inquiry has no amount, and so no currency to compare
+		// with; it falls in the first tier.
+		compared := amount
+		if compared.IsZero() {
+			compared = model.NewMoney(0, tier.UpTo.Currency)
+		}
+		cmp, err := compared.Cmp(tier.UpTo)
+		if err != nil {
+			return model.Money{}, err
+		}
+		if cmp <= 0 {
+			fixed, bp = tier.Fixed, tier.BasisPoints
+			break
+		}
+	}
+
+	return withPercentage(fixed, amount, bp)
+}
+
+// withPercentage adds bp basis points of amount to fixed, rounding half up
+// to the nearest minor unit.
+func withPercentage(fixed, amount model.Money, bp int64) (model.Money, error) {
+	if bp == 0 || amount.IsZero() {
+		return fixed, nil
+	}
+
+	scaled, err := amount.Mul(bp)
+	if err != nil {
+		return model.Money{}, err
+	}
+	pct := scaled.Amount / 10000
+	if scaled.Amount%10000 >= 5000 {
+		pct++
+	}
+
+	percentage := model.NewMoney(pct, amount.Currency)
+	if fixed.IsZero() {
+		return percentage, nil
+	}
+
+	return fixed.Add(percentage)
+}
@@ -0,0 +1,93 @@
+package fees
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+var (
+	onUsCard    = model.Card{Number: "4111111111111111"}
+	foreignCard = model.Card{Number: "5500000000000004"}
+)
+
+func TestFee(t *testing.T) {
+	s := Schedule{
+		OnUsBINs: []string{"411111"},
+		OnUs: map[Transaction]Rule{
+			BalanceInquiry: {},
+		},
+		Foreign: map[Transaction]Rule{
+			Withdrawal:     {Fixed: usd(250), BasisPoints: 100},
+			BalanceInquiry: {Fixed: usd(100)},
+		},
+	}
+
+	require.True(t, s.IsOnUs(onUsCard))
+	require.False(t, s.IsOnUs(foreignCard))
+
+	for _, tc := range []struct {
+		card     model.Card
+		txn      Transaction
+		amount   model.Money
+		expected model.Money
+	}{
+		{card: onUsCard, txn: Withdrawal, amount: usd(10000), expected: model.Money{}},
+		{card: onUsCard, txn: BalanceInquiry, expected: model.Money{}},
+		{card: foreignCard, txn: Withdrawal, amount: usd(10000), expected: usd(350)},
+		{card: foreignCard, txn: Withdrawal, amount: usd(12345), expected: usd(373)},
+		{card: foreignCard, txn: Withdrawal, amount: usd(12350), expected: usd(374)},
+		{card: foreignCard, txn: BalanceInquiry, expected: usd(100)},
+	} {
+		fee, err := s.Fee(tc.card, tc.txn, tc.amount)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, fee, "%s %s", tc.txn, tc.amount)
+	}
+}
+
+func TestTieredFee(t *testing.T) {
+	s := Schedule{
+		Foreign: map[Transaction]Rule{
+			Withdrawal: {Tiers: []Tier{
+				{UpTo: usd(10000), Fixed: usd(200)},
+				{UpTo: usd(50000), Fixed: usd(300)},
+				{BasisPoints: 75},
+			}},
+		},
+	}
+
+	for amount, expected := range map[int64]model.Money{
+		2000:   usd(200),
+		10000:  usd(200),
+		10001:  usd(300),
+		50000:  usd(300),
+		100000: usd(750),
+	} {
+		fee, err := s.Fee(foreignCard, Withdrawal, usd(amount))
+		require.NoError(t, err)
+		require.Equal(t, expected, fee, amount)
+	}
+
+	_, err := s.Fee(foreignCard, Withdrawal, model.NewMoney(2000, "EUR"))
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+}
+
+func TestTieredBalanceInquiryFee(t *testing.T) {
+	s := Schedule{
+		Foreign: map[Transaction]Rule{
+			BalanceInquiry: {Tiers: []Tier{
+				{UpTo: usd(10000), Fixed: usd(100)},
+				{Fixed: usd(200)},
+			}},
+		},
+	}
+
+	fee, err := s.Fee(foreignCard, BalanceInquiry, model.Money{})
+	require.NoError(t, err)
+	require.Equal(t, usd(100), fee)
+}
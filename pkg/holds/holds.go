@@ -0,0 +1,116 @@
+// Package holds works out which part of a cash deposit is held back, and
+// until when, under a funds availability schedule.
+package holds
+
+import (
+	"atm/pkg/model"
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Rule holds the part of each deposit above Above for Days business days.
+// With several rules, each part of a deposit is held by the rule with the
+// highest Above below it, so a schedule can hold larger deposits longer.
+type Rule struct {
+	Above model.Money
+	Days  int
+}
+
+// Policy is a funds availability schedule. The zero value holds nothing.
+type Policy struct {
+	Rules []Rule
+	// Location is the time zone business days are counted in. Defaults to UTC.
+	Location *time.Location
+	// Cutoff is the time of day after which a deposit counts as made on
+	// the next business day. Zero means no cutoff.
+	Cutoff time.Duration
+	// Holidays are dates, besides weekends, that are not business days.
+	Holidays []time.Time
+}
+
+// Hold is one part of a deposit that is not available until ReleaseAt.
+type Hold struct {
+	Amount    model.Money
+	ReleaseAt time.Time
+}
+
+// Holds returns the holds to place on a deposit of amount made at now. The
+// amounts add up to at most amount and the rest is available at once.
+func (p Policy) Holds(amount model.Money, now time.Time) ([]Hold, error) {
+	rules := slices.Clone(p.Rules)
+	slices.SortStableFunc(rules, func(a, b Rule) int {
+		return cmp.Compare(a.Above.Amount, b.Above.Amount)
+	})
+
+	var holds []Hold
+	for i, rule := range rules {
+		upper := amount
+		if i+1 < len(rules) {
+			c, err := amount.Cmp(rules[i+1].Above)
+			if err != nil {
+				return nil, err
+			}
+			if c > 0 {
+				upper = rules[i+1].Above
+			}
+		}
+
+		lower := rule.Above
+		if lower.IsZero() {
+			lower = model.Money{Currency: amount.Currency}
+		}
+		part, err := upper.Sub(lower)
+		if err != nil {
+			return nil, err
+		}
+		if !part.IsPositive() || rule.Days <= 0 {
+			continue
+		}
+		holds = append(holds, Hold{Amount: part, ReleaseAt: p.release(now, rule.Days)})
+	}
+
+	return holds, nil
+}
+
+// release returns the start of the business day days business days after
+// the deposit day of a deposit made at now.
+func (p Policy) release(now time.Time, days int) time.Time {
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if !p.businessDay(day) || (p.Cutoff > 0 && now.Sub(day) >= p.Cutoff) {
+		day = p.nextBusinessDay(day)
+	}
+	for range days {
+		day = p.nextBusinessDay(day)
+	}
+
+	return day
+}
+
+func (p Policy) nextBusinessDay(day time.Time) time.Time {
+	for {
+		day = day.AddDate(0, 0, 1)
+		if p.businessDay(day) {
+			return day
+		}
+	}
+}
+
+func (p Policy) businessDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	for _, h := range p.Holidays {
+		if h.Year() == day.Year() && h.Month() == day.Month() && h.Day() == day.Day() {
+			return false
+		}
+	}
+
+	return true
+}
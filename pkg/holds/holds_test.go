@@ -0,0 +1,84 @@
+package holds
+
+import (
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+func date(day, hour int) time.Time {
+	// 2024-01-01 is a Monday.
+	return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestHolds(t *testing.T) {
+	p := Policy{
+		Rules: []Rule{
+			{Above: usd(550000), Days: 5},
+			{Above: usd(22500), Days: 1},
+		},
+		Cutoff: 17 * time.Hour,
+	}
+
+	holds, err := p.Holds(usd(20000), date(1, 9))
+	require.NoError(t, err)
+	require.Empty(t, holds)
+
+	holds, err = p.Holds(usd(100000), date(1, 9))
+	require.NoError(t, err)
+	require.Equal(t, []Hold{{Amount: usd(77500), ReleaseAt: date(2, 0)}}, holds)
+
+	holds, err = p.Holds(usd(600000), date(1, 9))
+	require.NoError(t, err)
+	require.Equal(t, []Hold{
+		{Amount: usd(527500), ReleaseAt: date(2, 0)},
+		{Amount: usd(50000), ReleaseAt: date(8, 0)},
+	}, holds)
+}
+
+func TestHoldsBusinessDays(t *testing.T) {
+	p := Policy{
+		Rules:    []Rule{{Days: 1}},
+		Cutoff:   17 * time.Hour,
+		Holidays: []time.Time{date(8, 0)},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		now     time.Time
+		release time.Time
+	}{
+		{name: "before cutoff", now: date(1, 16), release: date(2, 0)},
+		{name: "after cutoff", now: date(1, 17), release: date(3, 0)},
+		{name: "friday", now: date(5, 9), release: date(9, 0)},
+		{name: "weekend", now: date(6, 9), release: date(10, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			holds, err := p.Holds(usd(10000), tc.now)
+			require.NoError(t, err)
+			require.Equal(t, []Hold{{Amount: usd(10000), ReleaseAt: tc.release}}, holds)
+		})
+	}
+}
+
+func TestHoldsLocation(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	p := Policy{Rules: []Rule{{Days: 1}}, Location: loc, Cutoff: 17 * time.Hour}
+
+	// 23:00 UTC on Monday is 18:00 in EST, after the cutoff.
+	holds, err := p.Holds(usd(10000), date(1, 23))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, loc), holds[0].ReleaseAt)
+}
+
+func TestHoldsCurrency(t *testing.T) {
+	p := Policy{Rules: []Rule{{Above: usd(100), Days: 1}}}
+
+	_, err := p.Holds(model.NewMoney(1000, "EUR"), date(1, 9))
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package testutil
+
+import (
+	"atm/pkg/acceptor"
+	"atm/pkg/service"
+	"context"
+	"errors"
+)
+
+type dummyAcceptorSvc struct {
+	opts DummyAcceptorTestOptions
+}
+
+func (d *dummyAcceptorSvc) Accept(ctx context.Context) (acceptor.Bundle, error) {
+	if d.opts.ErrOnAccept {
+		return acceptor.Bundle{}, errors.New("acceptor fault")
+	}
+
+	return acceptor.Bundle{Currency: Currency, Notes: d.opts.Notes}, nil
+}
+
+func (d *dummyAcceptorSvc) Stack(ctx context.Context) error {
+	if d.opts.ErrOnStack {
+		return errors.New("stacker jammed")
+	}
+	if d.opts.Stacked != nil {
+		*d.opts.Stacked = true
+	}
+
+	return nil
+}
+
+func (d *dummyAcceptorSvc) Return(ctx context.Context) error {
+	if d.opts.ErrOnReturn {
+		return errors.New("shutter fault")
+	}
+	if d.opts.Returned != nil {
+		*d.opts.Returned = true
+	}
+
+	return nil
+}
+
+type DummyAcceptorTestOptions struct {
+	// Notes is what Accept counts, in Currency.
+	Notes       []acceptor.Note
+	ErrOnAccept bool
+
+	ErrOnStack bool
+	// Stacked, if set, is set to true when Stack succeeds.
+	Stacked *bool
+
+	ErrOnReturn bool
+	// Returned, if set, is set to true when Return succeeds.
+	Returned *bool
+}
+
+func NewDummyAcceptorSvc(opts DummyAcceptorTestOptions) service.AcceptorInterface {
+	return &dummyAcceptorSvc{opts: opts}
+}
@@ -2,10 +2,14 @@ package testutil
 
 import (
 	"atm/pkg/model"
+	"atm/pkg/overdraft"
 	"atm/pkg/service"
 	"errors"
 )
 
+// Currency is the currency of the dummy account service's amounts.
+const Currency model.Currency = "USD"
+
 type dummyAcctSvc struct {
 	opts DummyAcctTestOptions
 }
@@ -19,9 +23,24 @@ func (d dummyAcctSvc) EnterPinNumber(card model.Card, number string) (bool, erro
 		return false, nil
 	}
 
+	if d.opts.ValidPinNumber != "" {
+		return number == d.opts.ValidPinNumber, nil
+	}
+
 	return true, nil
 }
 
+func (d dummyAcctSvc) ChangePinNumber(card model.Card, newNumber string) error {
+	if d.opts.ErrOnChangePin {
+		return errors.New("failed to change pin number")
+	}
+	if d.opts.ChangedPinNumber != nil {
+		*d.opts.ChangedPinNumber = newNumber
+	}
+
+	return nil
+}
+
 func (d dummyAcctSvc) GetAccountIDs() ([]string, error) {
 	if d.opts.ErrOnGetAccountIDs {
 		return nil, errors.New("failed to get accountIDs")
@@ -39,6 +58,9 @@ func (d dummyAcctSvc) SelectAccountID(accountID string) error {
 }
 
 func (d dummyAcctSvc) GetBalance(accountID string) (int, error) {
+	if d.opts.GetBalanceBlock != nil {
+		<-d.opts.GetBalanceBlock
+	}
 	if d.opts.ErrOnGetBalance {
 		return 0, errors.New("failed to get balance")
 	}
@@ -62,9 +84,111 @@ func (d dummyAcctSvc) Withdraw(accountID string, withdrawAmount int) (int, error
 	return d.opts.BalanceAfterWithdraw, nil
 }
 
+// GetBalances reports GetBalanceAmt as the ledger balance, less Holds as
+// the available balance.
+func (d dummyAcctSvc) GetBalances(accountID string) (int, int, []service.LegacyHold, error) {
+	ledger, err := d.GetBalance(accountID)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return ledger, d.available(), d.opts.Holds, nil
+}
+
+func (d dummyAcctSvc) available() int {
+	available := d.opts.GetBalanceAmt
+	for _, h := range d.opts.Holds {
+		available -= h.Amount
+	}
+
+	return available
+}
+
+func (d dummyAcctSvc) DepositWithHolds(accountID string, amount int, holds []service.LegacyHold) (int, error) {
+	if d.opts.ErrOnMakeDeposit {
+		return 0, errors.New("failed to make deposit")
+	}
+	if d.opts.DepositedHolds != nil {
+		*d.opts.DepositedHolds = append(*d.opts.DepositedHolds, holds...)
+	}
+
+	return d.opts.BalanceAfterDeposit, nil
+}
+
+// DebitWithCheck decides the debit with overdraft.Policy against the
+// available balance and, for a sweep, LinkedBalanceAmt.
+func (d dummyAcctSvc) DebitWithCheck(accountID string, amount, fee, overdraftLimit int, sweepFrom string) (int, error) {
+	if d.opts.WithdrawStarted != nil {
+		d.opts.WithdrawStarted <- struct{}{}
+	}
+	if d.opts.WithdrawBlock != nil {
+		<-d.opts.WithdrawBlock
+	}
+	if d.opts.ErrOnWithdraw {
+		return 0, errors.New("failed to withdraw")
+	}
+
+	policy := overdraft.Policy{Kind: overdraft.FixedLimit, Limit: model.NewMoney(int64(overdraftLimit), Currency)}
+	if sweepFrom != "" {
+		policy = overdraft.Policy{Kind: overdraft.LinkedSweep, LinkedAccountID: sweepFrom}
+	}
+	sweep, err := policy.Decide(
+		model.NewMoney(int64(d.available()), Currency),
+		model.NewMoney(int64(d.opts.LinkedBalanceAmt), Currency),
+		model.NewMoney(int64(amount+fee), Currency),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if d.opts.Swept != nil {
+		*d.opts.Swept += int(sweep.Amount)
+	}
+
+	return d.opts.BalanceAfterWithdraw, nil
+}
+
+func (d dummyAcctSvc) ReverseWithdrawal(accountID string, amount int) (int, error) {
+	if d.opts.ErrOnReverse {
+		return 0, errors.New("failed to reverse withdrawal")
+	}
+	if d.opts.Reversed != nil {
+		*d.opts.Reversed += amount
+	}
+
+	return d.opts.BalanceAfterReverse, nil
+}
+
+func (d dummyAcctSvc) DepositCheque(accountID string, micrLine string, amount int) (string, error) {
+	if d.opts.ErrOnDepositCheque {
+		return "", errors.New("failed to deposit cheque")
+	}
+	if d.opts.DepositedCheque != nil {
+		*d.opts.DepositedCheque = micrLine
+	}
+
+	return "hold-1", nil
+}
+
+func (d dummyAcctSvc) ChargeFee(accountID string, description string, amount int) (int, error) {
+	if d.opts.ErrOnChargeFee {
+		return 0, errors.New("failed to charge fee")
+	}
+	if d.opts.ChargedFees != nil {
+		*d.opts.ChargedFees = append(*d.opts.ChargedFees, description)
+	}
+
+	return d.opts.BalanceAfterFee, nil
+}
+
 type DummyAcctTestOptions struct {
 	ErrOnPinNumberEnter   bool
 	InvalidPinNumberEnter bool
+	// ValidPinNumber, if set, is the only PIN EnterPinNumber accepts.
+	ValidPinNumber string
+
+	ErrOnChangePin bool
+	// ChangedPinNumber, if set, receives the PIN passed to ChangePinNumber.
+	ChangedPinNumber *string
 
 	ErrOnGetAccountIDs   bool
 	ErrOnSelectAccountID bool
@@ -72,14 +196,50 @@ type DummyAcctTestOptions struct {
 
 	ErrOnGetBalance bool
 	GetBalanceAmt   int
+	// GetBalanceBlock, if set, makes GetBalance wait until it is closed.
+	GetBalanceBlock chan struct{}
+	// Holds are the holds on the account. They are taken off GetBalanceAmt
+	// for the available balance.
+	Holds []service.LegacyHold
 
 	ErrOnMakeDeposit    bool
 	BalanceAfterDeposit int
+	// DepositedHolds, if set, collects the holds passed to DepositWithHolds.
+	DepositedHolds *[]service.LegacyHold
 
 	ErrOnWithdraw        bool
 	BalanceAfterWithdraw int
+	// WithdrawStarted, if set, receives a value each time DebitWithCheck
+	// is called, before it waits on WithdrawBlock.
+	WithdrawStarted chan struct{}
+	// WithdrawBlock, if set, makes DebitWithCheck wait until it is closed.
+	WithdrawBlock chan struct{}
+	// LinkedBalanceAmt is the balance of the account DebitWithCheck sweeps from.
+	LinkedBalanceAmt int
+	// Swept, if set, accumulates the amounts DebitWithCheck swept.
+	Swept *int
+
+	ErrOnReverse        bool
+	BalanceAfterReverse int
+	// Reversed, if set, accumulates the amounts passed to ReverseWithdrawal.
+	Reversed *int
+
+	ErrOnDepositCheque bool
+	// DepositedCheque, if set, receives the MICR line passed to DepositCheque.
+	DepositedCheque *string
+
+	ErrOnChargeFee  bool
+	BalanceAfterFee int
+	// ChargedFees, if set, collects the descriptions passed to ChargeFee.
+	ChargedFees *[]string
 }
 
 func NewDummyAccountSvc(opts DummyAcctTestOptions) service.AccountInterface {
 	return &dummyAcctSvc{opts: opts}
 }
+
+// NewAccountAdapterOptions returns adapter options for the dummy account
+// service: the test PIN cipher and Currency.
+func NewAccountAdapterOptions() service.AccountAdapterOptions {
+	return service.AccountAdapterOptions{PinCipher: NewPinCipher(), Currency: Currency}
+}
@@ -26,9 +26,18 @@ func (d *dummyCardSvc) RemoveCard() error {
 	return nil
 }
 
+func (d *dummyCardSvc) RetainCard() error {
+	if d.opts.ErrOnRetain {
+		return errors.New("retain card error")
+	}
+
+	return nil
+}
+
 type DummyCardTestOptions struct {
 	ErrOnInsert bool
 	ErrOnRemove bool
+	ErrOnRetain bool
 }
 
 func NewDummyCardSvc(opts DummyCardTestOptions) service.CardInterface {
@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"atm/pkg/dispenser"
+	"atm/pkg/service"
+	"context"
+	"errors"
+	"slices"
+)
+
+type dummyDispenserSvc struct {
+	opts DummyDispenserTestOptions
+}
+
+func (d *dummyDispenserSvc) Dispense(ctx context.Context, mix dispenser.Mix) (dispenser.Mix, error) {
+	picked := slices.Clone(mix)
+	if d.opts.JamAfterNotes < 0 {
+		return nil, errors.New("dispenser jammed")
+	}
+	if d.opts.JamAfterNotes == 0 {
+		return picked, nil
+	}
+
+	remaining := d.opts.JamAfterNotes
+	for i := range picked {
+		picked[i].Count = min(picked[i].Count, remaining)
+		remaining -= picked[i].Count
+	}
+	if picked.Notes() == mix.Notes() {
+		return picked, nil
+	}
+
+	return picked, errors.New("dispenser jammed")
+}
+
+func (d *dummyDispenserSvc) Present(ctx context.Context) (bool, error) {
+	if d.opts.ErrOnPresent {
+		return false, errors.New("shutter fault")
+	}
+
+	return !d.opts.NotTaken, nil
+}
+
+type DummyDispenserTestOptions struct {
+	// JamAfterNotes, if positive, makes Dispense jam after picking that many
+	// notes. A negative value jams before any note is picked.
+	JamAfterNotes int
+	ErrOnPresent  bool
+	// NotTaken makes Present report that the customer left the cash.
+	NotTaken bool
+}
+
+func NewDummyDispenserSvc(opts DummyDispenserTestOptions) service.DispenserInterface {
+	return &dummyDispenserSvc{opts: opts}
+}
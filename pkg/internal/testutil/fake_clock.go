@@ -0,0 +1,32 @@
+package testutil
+
+import (
+	"atm/pkg/clock"
+	"sync"
+	"time"
+)
+
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+var _ clock.Clock = (*FakeClock)(nil)
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
@@ -0,0 +1,20 @@
+package testutil
+
+import (
+	"atm/pkg/pinblock"
+)
+
+var testPinKey = []byte{
+	0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF,
+	0xFE, 0xDC, 0xBA, 0x98, 0x76, 0x54, 0x32, 0x10,
+}
+
+// NewPinCipher returns a format 0 PIN block cipher under a fixed test key.
+func NewPinCipher() *pinblock.Cipher {
+	c, err := pinblock.NewCipher(pinblock.Format0, testPinKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
@@ -0,0 +1,98 @@
+// Package journal records each step of a cash transaction so that it can be
+// reconciled against what the dispenser or deposit acceptor actually did.
+package journal
+
+import (
+	"atm/pkg/dispenser"
+	"atm/pkg/model"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Event string
+
+const (
+	Debited Event = "debited"
+	// DebitUnknown marks a debit whose service call was abandoned when the
+	// context ended. It may still be posted, so it is left for
+	// reconciliation.
+	DebitUnknown   Event = "debit outcome unknown"
+	Dispensed      Event = "dispensed"
+	DispenseFailed Event = "dispense failed"
+	Presented      Event = "presented"
+	Taken          Event = "taken"
+	Retracted      Event = "retracted"
+	// PresentUnknown marks notes that may or may not have reached the
+	// customer. They are left for reconciliation rather than reversed.
+	PresentUnknown Event = "present outcome unknown"
+	Reversed       Event = "reversed"
+	ReversalFailed Event = "reversal failed"
+
+	Escrowed      Event = "escrowed"
+	Stacked       Event = "stacked"
+	NotesReturned Event = "notes returned"
+	Credited      Event = "credited"
+	CreditFailed  Event = "credit failed"
+
+	ChequeHeld Event = "cheque held"
+
+	FeeCharged    Event = "fee charged"
+	FeeChargeFail Event = "fee charge failed"
+)
+
+// Entry is one journaled step. Entries never carry card numbers.
+type Entry struct {
+	Time       time.Time
+	TerminalID string
+	AccountID  string
+	Event      Event
+	Amount     model.Money
+	// Notes are the notes involved in a dispense step.
+	Notes dispenser.Mix
+	// Err is the error that caused a failure step.
+	Err string
+}
+
+// Journal stores entries. Implementations must be safe for concurrent use.
+type Journal interface {
+	Record(entry Entry) error
+}
+
+// Memory is a Journal that keeps its entries in memory.
+type Memory struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Record(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = append(m.entries, entry)
+
+	return nil
+}
+
+// Entries returns a copy of the recorded entries in order.
+func (m *Memory) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.entries)
+}
+
+// Discard returns a Journal that drops every entry.
+func Discard() Journal {
+	return discard{}
+}
+
+type discard struct{}
+
+func (discard) Record(Entry) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+package journal
+
+import (
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	require.NoError(t, m.Record(Entry{Event: Debited, Amount: model.NewMoney(2000, "USD")}))
+	require.NoError(t, m.Record(Entry{Event: Dispensed}))
+
+	entries := m.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, Debited, entries[0].Event)
+
+	entries[0].Event = Reversed
+	require.Equal(t, Debited, m.Entries()[0].Event)
+
+	require.NoError(t, Discard().Record(Entry{Event: Debited}))
+}
@@ -0,0 +1,234 @@
+// Package limits enforces caps on cumulative withdrawals per card and per
+// account over daily and weekly windows.
+package limits
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Period int
+
+const (
+	Day Period = iota
+	Week
+)
+
+func (p Period) String() string {
+	switch p {
+	case Day:
+		return "daily"
+	case Week:
+		return "weekly"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Period) duration() time.Duration {
+	if p == Week {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+// Limit caps the withdrawals made in one window. A zero Card or Account
+// amount means that subject is not limited.
+type Limit struct {
+	Period Period
+	// Rolling windows cover the Period up to now. Calendar windows start at
+	// midnight, or at midnight on WeekStart for weekly limits.
+	Rolling bool
+	Card    model.Money
+	Account model.Money
+}
+
+func (l Limit) String() string {
+	kind := "calendar"
+	if l.Rolling {
+		kind = "rolling"
+	}
+
+	return kind + " " + l.Period.String()
+}
+
+type Config struct {
+	// Default applies to cards whose product has no entry in Products.
+	Default []Limit
+	// Products holds the limits for each card product.
+	Products map[string][]Limit
+	// Product returns a card's product. Defaults to the card's brand.
+	Product func(model.Card) string
+	// Location sets where calendar days begin. Defaults to UTC.
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// Engine tracks withdrawals and checks them against the configured limits.
+// It is safe for concurrent use and may be shared by several controllers
+// to enforce limits across terminals.
+type Engine struct {
+	mu       sync.Mutex
+	cfg      Config
+	cards    map[string][]record
+	accounts map[string][]record
+	lastID   uint64
+}
+
+type record struct {
+	// id identifies a reservation; it is zero for plain records.
+	id     uint64
+	at     time.Time
+	amount model.Money
+}
+
+func New(cfg Config) *Engine {
+	if cfg.Product == nil {
+		cfg.Product = func(card model.Card) string {
+			return string(card.Brand())
+		}
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+
+	return &Engine{
+		cfg:      cfg,
+		cards:    make(map[string][]record),
+		accounts: make(map[string][]record),
+	}
+}
+
+func (e *Engine) limitsFor(card model.Card) []Limit {
+	if limits, ok := e.cfg.Products[e.cfg.Product(card)]; ok {
+		return limits
+	}
+
+	return e.cfg.Default
+}
+
+// Reserve returns an error carrying errorcode.WithdrawalLimitExceeded if
+// withdrawing amount at now would take card or accountID over a limit.
+// Otherwise it counts the withdrawal against both in the same step, so
+// withdrawals made at the same time on different terminals cannot both
+// pass. The
+// returned release gives back part or all of the reservation for a
+// withdrawal that was declined or reversed.
+func (e *Engine) Reserve(card model.Card, accountID string, amount model.Money, now time.Time) (release func(model.Money), err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err = e.checkAll(card, accountID, amount, now); err != nil {
+		return nil, err
+	}
+	e.lastID++
+	id := e.lastID
+	e.add(card, accountID, record{id: id, at: now, amount: amount}, now)
+
+	return func(part model.Money) {
+		e.release(card.Number, accountID, id, part)
+	}, nil
+}
+
+func (e *Engine) release(pan string, accountID string, id uint64, part model.Money) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, records := range [][]record{e.cards[pan], e.accounts[accountID]} {
+		for i := range records {
+			if records[i].id != id {
+				continue
+			}
+			left, err := records[i].amount.Sub(part)
+			if err != nil {
+				continue
+			}
+			if left.IsNegative() {
+				left.Amount = 0
+			}
+			records[i].amount = left
+		}
+	}
+}
+
+func (e *Engine) checkAll(card model.Card, accountID string, amount model.Money, now time.Time) error {
+	for _, l := range e.limitsFor(card) {
+		if err := e.check(e.cards[card.Number], l, l.Card, "card", amount, now); err != nil {
+			return err
+		}
+		if err := e.check(e.accounts[accountID], l, l.Account, "account", amount, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) check(records []record, l Limit, maximum model.Money, subject string, amount model.Money, now time.Time) error {
+	if maximum.IsZero() {
+		return nil
+	}
+
+	used := model.NewMoney(0, amount.Currency)
+	start := e.windowStart(l, now)
+	for _, r := range records {
+		if !r.at.Before(start) && !r.at.After(now) {
+			var err error
+			if used, err = used.Add(r.amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	total, err := used.Add(amount)
+	if err != nil {
+		return err
+	}
+	cmp, err := total.Cmp(maximum)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return errorcode.Wrap(errorcode.WithdrawalLimitExceeded, fmt.Errorf("%s %s limit %s, %s already used", subject, l, maximum, used))
+	}
+
+	return nil
+}
+
+// windowStart returns the earliest time counted by l at now. Rolling
+// windows exclude their start instant, so it is nudged forward.
+func (e *Engine) windowStart(l Limit, now time.Time) time.Time {
+	if l.Rolling {
+		return now.Add(-l.Period.duration() + time.Nanosecond)
+	}
+
+	local := now.In(e.cfg.Location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.cfg.Location)
+	if l.Period == Week {
+		days := (int(local.Weekday()) - int(e.cfg.WeekStart) + 7) % 7
+		start = start.AddDate(0, 0, -days)
+	}
+
+	return start
+}
+
+func (e *Engine) add(card model.Card, accountID string, r record, now time.Time) {
+	e.cards[card.Number] = prune(append(e.cards[card.Number], r), now)
+	e.accounts[accountID] = prune(append(e.accounts[accountID], r), now)
+}
+
+// prune drops records too old to fall in any window: a week, plus a day of
+// slack for calendar windows that start before the rolling one.
+func prune(records []record, now time.Time) []record {
+	cutoff := now.Add(-Week.duration() - Day.duration())
+	i := 0
+	for i < len(records) && records[i].at.Before(cutoff) {
+		i++
+	}
+
+	return records[i:]
+}
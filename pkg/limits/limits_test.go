@@ -0,0 +1,137 @@
+package limits
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+var (
+	visa       = model.Card{Number: "4111111111111111"}
+	mastercard = model.Card{Number: "5500000000000004"}
+)
+
+// fits reports whether amount could be reserved at now, giving the
+// reservation back straight away.
+func fits(e *Engine, card model.Card, accountID string, amount model.Money, now time.Time) error {
+	release, err := e.Reserve(card, accountID, amount, now)
+	if err != nil {
+		return err
+	}
+	release(amount)
+
+	return nil
+}
+
+func reserve(t *testing.T, e *Engine, card model.Card, accountID string, amount model.Money, now time.Time) func(model.Money) {
+	t.Helper()
+
+	release, err := e.Reserve(card, accountID, amount, now)
+	require.NoError(t, err)
+
+	return release
+}
+
+func TestCalendarDay(t *testing.T) {
+	e := New(Config{Default: []Limit{{Period: Day, Card: usd(50000)}}})
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	reserve(t, e, visa, "acct", usd(40000), now)
+
+	require.ErrorIs(t, fits(e, visa, "acct", usd(20000), now), errorcode.ErrWithdrawalLimitExceeded)
+	require.NoError(t, fits(e, visa, "acct", usd(10000), now))
+	require.NoError(t, fits(e, mastercard, "acct", usd(20000), now))
+
+	// A new calendar day starts at midnight.
+	require.NoError(t, fits(e, visa, "acct", usd(50000), now.Add(time.Hour)))
+}
+
+func TestRollingDay(t *testing.T) {
+	e := New(Config{Default: []Limit{{Period: Day, Rolling: true, Account: usd(50000)}}})
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	reserve(t, e, visa, "acct", usd(40000), now)
+	require.ErrorIs(t, fits(e, mastercard, "acct", usd(20000), now.Add(time.Hour)), errorcode.ErrWithdrawalLimitExceeded)
+	require.NoError(t, fits(e, mastercard, "other", usd(20000), now.Add(time.Hour)))
+	require.NoError(t, fits(e, mastercard, "acct", usd(20000), now.Add(24*time.Hour)))
+}
+
+func TestCalendarWeek(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	e := New(Config{
+		Default:   []Limit{{Period: Week, Card: usd(100000)}},
+		Location:  loc,
+		WeekStart: time.Monday,
+	})
+	// Monday 1 January 2024, 09:00 local.
+	monday := time.Date(2024, 1, 1, 9, 0, 0, 0, loc)
+
+	reserve(t, e, visa, "acct", usd(60000), monday)
+	require.ErrorIs(t, fits(e, visa, "acct", usd(50000), monday.AddDate(0, 0, 6)), errorcode.ErrWithdrawalLimitExceeded)
+	require.NoError(t, fits(e, visa, "acct", usd(50000), time.Date(2024, 1, 8, 0, 0, 0, 0, loc)))
+}
+
+func TestProducts(t *testing.T) {
+	e := New(Config{
+		Default: []Limit{{Period: Day, Card: usd(20000)}},
+		Products: map[string][]Limit{
+			string(model.BrandMastercard): {{Period: Day, Card: usd(100000)}},
+		},
+	})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.ErrorIs(t, fits(e, visa, "acct", usd(30000), now), errorcode.ErrWithdrawalLimitExceeded)
+	require.NoError(t, fits(e, mastercard, "acct", usd(30000), now))
+}
+
+func TestRelease(t *testing.T) {
+	e := New(Config{Default: []Limit{{Period: Day, Rolling: true, Card: usd(50000)}}})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	release := reserve(t, e, visa, "acct", usd(50000), now)
+	require.ErrorIs(t, fits(e, visa, "acct", usd(10000), now), errorcode.ErrWithdrawalLimitExceeded)
+
+	release(usd(20000))
+	require.NoError(t, fits(e, visa, "acct", usd(20000), now))
+}
+
+func TestReserve(t *testing.T) {
+	e := New(Config{Default: []Limit{{Period: Day, Card: usd(50000), Account: usd(50000)}}})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		releases []func(model.Money)
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, err := e.Reserve(visa, "acct", usd(10000), now); err == nil {
+				mu.Lock()
+				releases = append(releases, release)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, releases, 5)
+	require.ErrorIs(t, fits(e, visa, "acct", usd(100), now), errorcode.ErrWithdrawalLimitExceeded)
+
+	releases[0](usd(4000))
+	require.NoError(t, fits(e, visa, "acct", usd(4000), now))
+	require.ErrorIs(t, fits(e, visa, "acct", usd(4100), now), errorcode.ErrWithdrawalLimitExceeded)
+
+	// Releasing more than was reserved gives back only the reservation.
+	releases[1](usd(30000))
+	require.NoError(t, fits(e, visa, "acct", usd(14000), now))
+	require.ErrorIs(t, fits(e, visa, "acct", usd(14100), now), errorcode.ErrWithdrawalLimitExceeded)
+}
@@ -0,0 +1,273 @@
+// Package magstripe parses ISO 7813 magnetic stripe track data into a
+// model.Card.
+//
+// Tracks are given as the ASCII characters the reader decoded, including the
+// start sentinel, end sentinel and, optionally, the LRC character that
+// follows the end sentinel.
+package magstripe
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"strings"
+)
+
+const (
+	track1Start     = '%'
+	track1Format    = 'B'
+	track1Separator = '^'
+	track2Start     = ';'
+	track2Separator = '='
+	endSentinel     = '?'
+
+	// The ISO 7813 maximum lengths include the sentinels and the LRC.
+	maxTrack1Length = 79
+	maxTrack2Length = 40
+)
+
+// Track1 is the content of an ISO 7813 Track 1 (IATA) record.
+type Track1 struct {
+	PAN               string
+	Name              string
+	Expiry            string
+	ServiceCode       string
+	DiscretionaryData string
+}
+
+// Track2 is the content of an ISO 7813 Track 2 (ABA) record.
+type Track2 struct {
+	PAN               string
+	Expiry            string
+	ServiceCode       string
+	DiscretionaryData string
+}
+
+// ParseError reports where in a track parsing failed.
+type ParseError struct {
+	Track  int
+	Offset int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("track %d offset %d: %s", e.Track, e.Offset, e.Reason)
+}
+
+func parseErr(track, offset int, format string, args ...any) error {
+	return errorcode.Wrap(errorcode.TrackParseFail, &ParseError{
+		Track:  track,
+		Offset: offset,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+// ParseTrack1 parses %B<PAN>^<NAME>^<YYMM><SSS><discretionary>?[LRC].
+func ParseTrack1(data string) (Track1, error) {
+	body, err := frame(1, data, track1Start, maxTrack1Length, 0x20, 0x3F)
+	if err != nil {
+		return Track1{}, err
+	}
+
+	if len(body) == 0 || body[0] != track1Format {
+		return Track1{}, parseErr(1, 1, "format code must be %q", track1Format)
+	}
+
+	fields := strings.SplitN(body[1:], string(track1Separator), 3)
+	if len(fields) != 3 {
+		return Track1{}, parseErr(1, 2, "expected 3 fields separated by %q, got %d", track1Separator, len(fields))
+	}
+
+	pan, name, rest := fields[0], fields[1], fields[2]
+	if err := checkPan(1, 2, pan); err != nil {
+		return Track1{}, err
+	}
+	if len(name) < 2 || len(name) > 26 {
+		return Track1{}, parseErr(1, 3+len(pan), "name must be 2 to 26 characters, got %d", len(name))
+	}
+
+	restOffset := 4 + len(pan) + len(name)
+	expiry, serviceCode, discretionary, err := splitTail(1, restOffset, rest)
+	if err != nil {
+		return Track1{}, err
+	}
+
+	return Track1{
+		PAN:               pan,
+		Name:              name,
+		Expiry:            expiry,
+		ServiceCode:       serviceCode,
+		DiscretionaryData: discretionary,
+	}, nil
+}
+
+// ParseTrack2 parses ;<PAN>=<YYMM><SSS><discretionary>?[LRC].
+func ParseTrack2(data string) (Track2, error) {
+	body, err := frame(2, data, track2Start, maxTrack2Length, 0x30, 0x0F)
+	if err != nil {
+		return Track2{}, err
+	}
+
+	sep := strings.IndexByte(body, track2Separator)
+	if sep < 0 {
+		return Track2{}, parseErr(2, 1, "missing field separator %q", track2Separator)
+	}
+
+	pan := body[:sep]
+	if err := checkPan(2, 1, pan); err != nil {
+		return Track2{}, err
+	}
+
+	expiry, serviceCode, discretionary, err := splitTail(2, sep+2, body[sep+1:])
+	if err != nil {
+		return Track2{}, err
+	}
+	for i := range discretionary {
+		if discretionary[i] < '0' || discretionary[i] > '9' {
+			return Track2{}, parseErr(2, sep+9+i, "discretionary data must be digits")
+		}
+	}
+
+	return Track2{
+		PAN:               pan,
+		Expiry:            expiry,
+		ServiceCode:       serviceCode,
+		DiscretionaryData: discretionary,
+	}, nil
+}
+
+// Card returns the card described by track 1, which is the only track that
+// carries the cardholder name.
+func (t Track1) Card() model.Card {
+	return model.Card{
+		HolderName:  model.HolderNameFromTrack(t.Name),
+		Number:      t.PAN,
+		Expiry:      t.Expiry,
+		ServiceCode: t.ServiceCode,
+	}
+}
+
+func (t Track2) Card() model.Card {
+	return model.Card{
+		Number:      t.PAN,
+		Expiry:      t.Expiry,
+		ServiceCode: t.ServiceCode,
+	}
+}
+
+// ParseCard parses whichever tracks are present and returns the card they
+// describe. When both are given they must agree on PAN, expiry and service
+// code.
+func ParseCard(track1, track2 string) (model.Card, error) {
+	switch {
+	case track1 == "" && track2 == "":
+		return model.Card{}, errorcode.Wrap(errorcode.TrackParseFail, fmt.Errorf("no track data"))
+	case track2 == "":
+		t1, err := ParseTrack1(track1)
+		return t1.Card(), err
+	case track1 == "":
+		t2, err := ParseTrack2(track2)
+		return t2.Card(), err
+	}
+
+	t1, err := ParseTrack1(track1)
+	if err != nil {
+		return model.Card{}, err
+	}
+	t2, err := ParseTrack2(track2)
+	if err != nil {
+		return model.Card{}, err
+	}
+	if t1.PAN != t2.PAN || t1.Expiry != t2.Expiry || t1.ServiceCode != t2.ServiceCode {
+		return model.Card{}, errorcode.ErrTrackMismatch
+	}
+
+	return t1.Card(), nil
+}
+
+// frame checks the sentinels, character set and LRC of a track and returns
+// the data between the sentinels. Characters are mapped to their bit values
+// by subtracting base; mask selects the data bits covered by the LRC.
+func frame(track int, data string, start byte, maxLen int, base byte, mask byte) (string, error) {
+	if len(data) == 0 || data[0] != start {
+		return "", parseErr(track, 0, "missing start sentinel %q", start)
+	}
+
+	end := strings.IndexByte(data, endSentinel)
+	if end < 0 {
+		return "", parseErr(track, len(data), "missing end sentinel %q", endSentinel)
+	}
+	// maxLen leaves room for the LRC even when the reader did not pass it on.
+	if end+2 > maxLen {
+		return "", parseErr(track, maxLen-1, "track longer than %d characters", maxLen)
+	}
+
+	var lrc byte
+	for i := 0; i <= end; i++ {
+		c := data[i]
+		if c < base || c-base > mask {
+			return "", parseErr(track, i, "invalid character %q", c)
+		}
+		lrc ^= c - base
+	}
+
+	switch len(data) - end - 1 {
+	case 0:
+	case 1:
+		got := data[end+1]
+		if got < base || got-base > mask || got-base != lrc {
+			return "", errorcode.Wrap(errorcode.TrackLRCMismatch, &ParseError{
+				Track:  track,
+				Offset: end + 1,
+				Reason: fmt.Sprintf("lrc %q, want %q", got, lrc+base),
+			})
+		}
+	default:
+		return "", parseErr(track, end+2, "unexpected data after lrc")
+	}
+
+	return data[1:end], nil
+}
+
+// LRC returns the longitudinal redundancy check character for a track 1
+// (track == 1) or track 2 record running from start to end sentinel.
+func LRC(track int, data string) byte {
+	base := byte(0x30)
+	if track == 1 {
+		base = 0x20
+	}
+
+	var lrc byte
+	for i := range data {
+		lrc ^= data[i] - base
+	}
+
+	return lrc + base
+}
+
+func checkPan(track, offset int, pan string) error {
+	if len(pan) < 12 || len(pan) > 19 {
+		return parseErr(track, offset, "pan must be 12 to 19 digits, got %d", len(pan))
+	}
+	for i := range pan {
+		if pan[i] < '0' || pan[i] > '9' {
+			return parseErr(track, offset+i, "pan must be digits")
+		}
+	}
+
+	return nil
+}
+
+// splitTail splits YYMM, service code and discretionary data.
+func splitTail(track, offset int, s string) (expiry, serviceCode, discretionary string, err error) {
+	if len(s) < 7 {
+		return "", "", "", parseErr(track, offset, "expiry and service code need 7 characters, got %d", len(s))
+	}
+	for i := 0; i < 7; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return "", "", "", parseErr(track, offset+i, "expiry and service code must be digits")
+		}
+	}
+
+	return s[:4], s[4:7], s[7:], nil
+}
@@ -0,0 +1,143 @@
+package magstripe
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const (
+	testTrack1 = "%B4111111111111111^DOE/JOHN^2512101123456789?"
+	testTrack2 = ";4111111111111111=2512101123456789?"
+)
+
+func withLRC(track int, data string) string {
+	return data + string(LRC(track, data))
+}
+
+func TestParseTrack1(t *testing.T) {
+	for _, data := range []string{testTrack1, withLRC(1, testTrack1)} {
+		t1, err := ParseTrack1(data)
+		require.NoError(t, err)
+		require.Equal(t, Track1{
+			PAN:               "4111111111111111",
+			Name:              "DOE/JOHN",
+			Expiry:            "2512",
+			ServiceCode:       "101",
+			DiscretionaryData: "123456789",
+		}, t1)
+		require.Equal(t, model.Card{
+			HolderName:  "JOHN DOE",
+			Number:      "4111111111111111",
+			Expiry:      "2512",
+			ServiceCode: "101",
+		}, t1.Card())
+	}
+}
+
+func TestParseTrack2(t *testing.T) {
+	for _, data := range []string{testTrack2, withLRC(2, testTrack2)} {
+		t2, err := ParseTrack2(data)
+		require.NoError(t, err)
+		require.Equal(t, Track2{
+			PAN:               "4111111111111111",
+			Expiry:            "2512",
+			ServiceCode:       "101",
+			DiscretionaryData: "123456789",
+		}, t2)
+	}
+}
+
+func TestParseCard(t *testing.T) {
+	card, err := ParseCard(withLRC(1, testTrack1), withLRC(2, testTrack2))
+	require.NoError(t, err)
+	require.Equal(t, "JOHN DOE", card.HolderName)
+	require.Equal(t, "4111111111111111", card.Number)
+
+	card, err = ParseCard("", testTrack2)
+	require.NoError(t, err)
+	require.Equal(t, "", card.HolderName)
+	require.Equal(t, "2512", card.Expiry)
+
+	_, err = ParseCard(testTrack1, ";5500000000000004=2512101123456789?")
+	require.ErrorIs(t, err, errorcode.ErrTrackMismatch)
+
+	_, err = ParseCard("", "")
+	require.ErrorIs(t, err, errorcode.ErrTrackParseFail)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		track  int
+		data   string
+		offset int
+	}{
+		{"no start sentinel", 1, "B4111111111111111^DOE/JOHN^2512101?", 0},
+		{"wrong format code", 1, "%A4111111111111111^DOE/JOHN^2512101?", 1},
+		{"missing separator", 1, "%B4111111111111111^DOE/JOHN2512101?", 2},
+		{"short pan", 1, "%B41111111^DOE/JOHN^2512101?", 2},
+		{"short name", 1, "%B4111111111111111^D^2512101?", 19},
+		{"short tail", 1, "%B4111111111111111^DOE/JOHN^2512?", 28},
+		{"no end sentinel", 2, ";4111111111111111=2512101", 25},
+		{"invalid character", 2, ";4111111111111111=25121A1?", 23},
+		{"no separator", 2, ";41111111111111112512101?", 1},
+		{"pan letters", 2, ";41111111111111:1=2512101?", 15},
+		{"trailing data", 2, ";4111111111111111=2512101?00", 27},
+	} {
+		var err error
+		if tc.track == 1 {
+			_, err = ParseTrack1(tc.data)
+		} else {
+			_, err = ParseTrack2(tc.data)
+		}
+		require.ErrorIs(t, err, errorcode.ErrTrackParseFail, tc.name)
+
+		var parseErr *ParseError
+		require.True(t, errors.As(err, &parseErr), tc.name)
+		require.Equal(t, tc.track, parseErr.Track, tc.name)
+		require.Equal(t, tc.offset, parseErr.Offset, tc.name)
+	}
+}
+
+func TestLRCMismatch(t *testing.T) {
+	data := withLRC(2, testTrack2)
+	bad := data[:len(data)-1] + string(data[len(data)-1]^1)
+
+	_, err := ParseTrack2(bad)
+	require.ErrorIs(t, err, errorcode.ErrTrackLRCMismatch)
+}
+
+func TestTrackLength(t *testing.T) {
+	// The longest tracks, 79 and 40 characters with the LRC.
+	track1 := "%B4111111111111111^DOE/JOHN^2512101" + strings.Repeat("1", 42) + "?"
+	track2 := ";4111111111111111=2512101" + strings.Repeat("1", 13) + "?"
+	require.Len(t, withLRC(1, track1), 79)
+	require.Len(t, withLRC(2, track2), 40)
+
+	_, err := ParseTrack1(withLRC(1, track1))
+	require.NoError(t, err)
+	_, err = ParseTrack2(withLRC(2, track2))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		track int
+		data  string
+	}{
+		{1, withLRC(1, track1[:len(track1)-1]+"1?")},
+		{1, track1[:len(track1)-1] + "1?"},
+		{2, withLRC(2, track2[:len(track2)-1]+"1?")},
+		{2, track2[:len(track2)-1] + "1?"},
+	} {
+		var err error
+		if tc.track == 1 {
+			_, err = ParseTrack1(tc.data)
+		} else {
+			_, err = ParseTrack2(tc.data)
+		}
+		require.ErrorIs(t, err, errorcode.ErrTrackParseFail, tc.data)
+	}
+}
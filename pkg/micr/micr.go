@@ -0,0 +1,238 @@
+// Package micr parses the E-13B MICR line printed along the bottom of a
+// cheque.
+//
+// The four E-13B control symbols may be given either as the Unicode OCR
+// characters (⑆ transit, ⑈ on-us, ⑇ amount, ⑉ dash) or as the ASCII letters
+// T, U, A and D that many readers substitute for them. Spaces are ignored.
+package micr
+
+import (
+	"atm/pkg/errorcode"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	transit = 'T'
+	onUs    = 'U'
+	amount  = 'A'
+	dash    = 'D'
+
+	routingLength = 9
+	amountLength  = 10
+)
+
+var symbols = map[rune]rune{
+	'⑆': transit, 'T': transit, 't': transit,
+	'⑈': onUs, 'U': onUs, 'u': onUs,
+	'⑇': amount, 'A': amount, 'a': amount,
+	'⑉': dash, 'D': dash, 'd': dash,
+}
+
+// Line is the content of a cheque's MICR line.
+type Line struct {
+	// Routing is the 9-digit ABA routing number of the paying bank.
+	Routing string
+	// Account is the payer's account number. Dash symbols are kept as '-'.
+	Account string
+	// Serial is the cheque number, taken from the auxiliary on-us field on
+	// business cheques or from after the account number on personal ones.
+	Serial string
+	// Amount is the encoded amount in minor units. It is only present once
+	// a bank has encoded the cheque.
+	Amount    int64
+	HasAmount bool
+}
+
+// String returns the line with ASCII control symbols.
+func (l Line) String() string {
+	var b strings.Builder
+	if l.Serial != "" {
+		fmt.Fprintf(&b, "U%sU ", l.Serial)
+	}
+	fmt.Fprintf(&b, "T%sT %sU", l.Routing, strings.ReplaceAll(l.Account, "-", "D"))
+	if l.HasAmount {
+		fmt.Fprintf(&b, " A%0*dA", amountLength, l.Amount)
+	}
+
+	return b.String()
+}
+
+// ParseError reports where in a MICR line parsing failed. Offset counts
+// characters, not bytes.
+type ParseError struct {
+	Offset int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Reason)
+}
+
+func parseErr(offset int, format string, args ...any) error {
+	return errorcode.Wrap(errorcode.MICRParseFail, &ParseError{
+		Offset: offset,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+// token is a run of digits or a single control symbol.
+type token struct {
+	symbol rune
+	digits string
+	offset int
+}
+
+func tokenize(line string) ([]token, error) {
+	var tokens []token
+	offset := 0
+	for _, r := range line {
+		switch {
+		case r == ' ':
+		case r >= '0' && r <= '9':
+			if n := len(tokens); n > 0 && tokens[n-1].symbol == 0 {
+				tokens[n-1].digits += string(r)
+			} else {
+				tokens = append(tokens, token{digits: string(r), offset: offset})
+			}
+		default:
+			symbol, ok := symbols[r]
+			if !ok {
+				return nil, parseErr(offset, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{symbol: symbol, offset: offset})
+		}
+		offset++
+	}
+
+	return tokens, nil
+}
+
+// Parse parses a MICR line of the form
+//
+//	[⑈serial⑈] ⑆routing⑆ account⑈[serial] [⑇amount⑇]
+//
+// and checks the routing number's check digit. Errors carry
+// errorcode.MICRParseFail or errorcode.RoutingCheckFail.
+func Parse(line string) (Line, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return Line{}, err
+	}
+
+	var l Line
+	i := 0
+	end := len([]rune(line))
+	at := func(i int) int {
+		if i < len(tokens) {
+			return tokens[i].offset
+		}
+		return end
+	}
+	expect := func(symbol rune) error {
+		if i >= len(tokens) || tokens[i].symbol != symbol {
+			return parseErr(at(i), "expected %c symbol", symbol)
+		}
+		i++
+		return nil
+	}
+
+	// Auxiliary on-us field.
+	if i < len(tokens) && tokens[i].symbol == onUs {
+		i++
+		if i >= len(tokens) || tokens[i].symbol != 0 {
+			return Line{}, parseErr(at(i), "expected serial number in auxiliary on-us field")
+		}
+		l.Serial = tokens[i].digits
+		i++
+		if err := expect(onUs); err != nil {
+			return Line{}, err
+		}
+	}
+
+	// Transit field.
+	if err := expect(transit); err != nil {
+		return Line{}, err
+	}
+	if i >= len(tokens) || len(tokens[i].digits) != routingLength {
+		return Line{}, parseErr(at(i), "routing number must be %d digits", routingLength)
+	}
+	l.Routing = tokens[i].digits
+	i++
+	if err := expect(transit); err != nil {
+		return Line{}, err
+	}
+
+	// On-us field: the account number, then the serial on personal cheques.
+	var account strings.Builder
+	for ; i < len(tokens) && tokens[i].symbol != onUs; i++ {
+		switch tokens[i].symbol {
+		case 0:
+			account.WriteString(tokens[i].digits)
+		case dash:
+			account.WriteByte('-')
+		default:
+			return Line{}, parseErr(at(i), "unexpected %c symbol in account number", tokens[i].symbol)
+		}
+	}
+	l.Account = strings.Trim(account.String(), "-")
+	if l.Account == "" {
+		return Line{}, parseErr(at(i), "missing account number")
+	}
+	if err := expect(onUs); err != nil {
+		return Line{}, err
+	}
+	if i < len(tokens) && tokens[i].symbol == 0 {
+		if l.Serial != "" {
+			return Line{}, parseErr(at(i), "serial number given twice")
+		}
+		l.Serial = tokens[i].digits
+		i++
+	}
+
+	// Amount field.
+	if i < len(tokens) {
+		if err := expect(amount); err != nil {
+			return Line{}, err
+		}
+		if i >= len(tokens) || len(tokens[i].digits) != amountLength {
+			return Line{}, parseErr(at(i), "amount must be %d digits", amountLength)
+		}
+		l.Amount, _ = strconv.ParseInt(tokens[i].digits, 10, 64)
+		l.HasAmount = true
+		i++
+		if err := expect(amount); err != nil {
+			return Line{}, err
+		}
+	}
+	if i < len(tokens) {
+		return Line{}, parseErr(at(i), "unexpected data after amount field")
+	}
+
+	if !ValidRouting(l.Routing) {
+		return Line{}, errorcode.Wrap(errorcode.RoutingCheckFail, fmt.Errorf("%s", l.Routing))
+	}
+
+	return l, nil
+}
+
+// ValidRouting reports whether routing is a 9-digit ABA routing number with
+// a correct check digit: 3, 7 and 1 weights repeated across the digits must
+// sum to a multiple of 10.
+func ValidRouting(routing string) bool {
+	if len(routing) != routingLength {
+		return false
+	}
+
+	weights := [3]int{3, 7, 1}
+	sum := 0
+	for i := range routing {
+		if routing[i] < '0' || routing[i] > '9' {
+			return false
+		}
+		sum += weights[i%3] * int(routing[i]-'0')
+	}
+
+	return sum%10 == 0
+}
@@ -0,0 +1,77 @@
+package micr
+
+import (
+	"atm/pkg/errorcode"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected Line
+	}{
+		{
+			line:     "⑆123456780⑆ 12345678⑈ 0402",
+			expected: Line{Routing: "123456780", Account: "12345678", Serial: "0402"},
+		},
+		{
+			line:     "⑈001234⑈ ⑆021000021⑆ 98⑉7654⑉321⑈ ⑇0000012500⑇",
+			expected: Line{Routing: "021000021", Account: "98-7654-321", Serial: "001234", Amount: 12500, HasAmount: true},
+		},
+		{
+			line:     "T123456780T 12345678U",
+			expected: Line{Routing: "123456780", Account: "12345678"},
+		},
+		{
+			line:     "t123456780t12345678u0402a0000000999a",
+			expected: Line{Routing: "123456780", Account: "12345678", Serial: "0402", Amount: 999, HasAmount: true},
+		},
+	} {
+		l, err := Parse(tc.line)
+		require.NoError(t, err, tc.line)
+		require.Equal(t, tc.expected, l, tc.line)
+
+		again, err := Parse(l.String())
+		require.NoError(t, err, l.String())
+		require.Equal(t, tc.expected, again)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		offset int
+	}{
+		{line: "⑆123456780⑆ 12345678⑈ 04X2", offset: 24},
+		{line: "12345678⑈", offset: 0},
+		{line: "⑆12345678⑆ 12345678⑈", offset: 1},
+		{line: "⑆123456780 12345678⑈", offset: 1},
+		{line: "⑆123456780⑆ ⑈", offset: 12},
+		{line: "⑆123456780⑆ 12345678", offset: 20},
+		{line: "⑆123456780⑆ 12345678⑈ ⑇125⑇", offset: 23},
+		{line: "⑆123456780⑆ 12345678⑈ ⑇0000012500", offset: 33},
+		{line: "⑈0402⑈ ⑆123456780⑆ 12345678⑈ 0402", offset: 29},
+		{line: "⑆123456780⑆ 123⑆45678⑈", offset: 15},
+	} {
+		_, err := Parse(tc.line)
+		require.ErrorIs(t, err, errorcode.ErrMICRParseFail, tc.line)
+
+		var parseErr *ParseError
+		require.True(t, errors.As(err, &parseErr), tc.line)
+		require.Equal(t, tc.offset, parseErr.Offset, tc.line)
+	}
+
+	_, err := Parse("⑆123456789⑆ 12345678⑈")
+	require.ErrorIs(t, err, errorcode.ErrRoutingCheckFail)
+}
+
+func TestValidRouting(t *testing.T) {
+	for _, routing := range []string{"021000021", "011000138", "123456780", "111000025"} {
+		require.True(t, ValidRouting(routing), routing)
+	}
+	for _, routing := range []string{"021000022", "12345678", "1234567800", "12345678a", ""} {
+		require.False(t, ValidRouting(routing), routing)
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+type Brand string
+
+const (
+	BrandUnknown    Brand = "unknown"
+	BrandVisa       Brand = "visa"
+	BrandMastercard Brand = "mastercard"
+	BrandAmex       Brand = "amex"
+	BrandUnionPay   Brand = "unionpay"
+	BrandDiscover   Brand = "discover"
+	BrandJCB        Brand = "jcb"
+	BrandDiners     Brand = "diners"
+	BrandMaestro    Brand = "maestro"
+	// Domestic schemes.
+	BrandMir   Brand = "mir"
+	BrandTroy  Brand = "troy"
+	BrandVerve Brand = "verve"
+)
+
+// binRange maps the IINs from low to high inclusive, which must have the same
+// number of digits, to a brand and the PAN lengths that brand issues.
+type binRange struct {
+	low, high string
+	brand     Brand
+	lengths   []int
+}
+
+var (
+	lengths16to19 = []int{16, 17, 18, 19}
+	lengths12to19 = []int{12, 13, 14, 15, 16, 17, 18, 19}
+)
+
+// binRanges is searched longest prefix first so that co-branded ranges such
+// as Discover's 622126-622925 win over the broader UnionPay 62.
+var binRanges = []binRange{
+	{"506099", "506198", BrandVerve, lengths16to19},
+	{"650002", "650027", BrandVerve, lengths16to19},
+	{"622126", "622925", BrandDiscover, lengths16to19},
+
+	{"2221", "2720", BrandMastercard, []int{16}},
+	{"2200", "2204", BrandMir, lengths16to19},
+	{"9792", "9792", BrandTroy, []int{16}},
+	{"3528", "3589", BrandJCB, lengths16to19},
+	{"6011", "6011", BrandDiscover, lengths16to19},
+	{"5018", "5018", BrandMaestro, lengths12to19},
+	{"5020", "5020", BrandMaestro, lengths12to19},
+	{"5038", "5038", BrandMaestro, lengths12to19},
+	{"5893", "5893", BrandMaestro, lengths12to19},
+	{"6304", "6304", BrandMaestro, lengths12to19},
+	{"6759", "6759", BrandMaestro, lengths12to19},
+	{"6761", "6763", BrandMaestro, lengths12to19},
+
+	{"300", "305", BrandDiners, []int{14, 15, 16, 17, 18, 19}},
+	{"644", "649", BrandDiscover, lengths16to19},
+
+	{"34", "34", BrandAmex, []int{15}},
+	{"37", "37", BrandAmex, []int{15}},
+	{"36", "36", BrandDiners, []int{14, 15, 16, 17, 18, 19}},
+	{"38", "39", BrandDiners, []int{16, 17, 18, 19}},
+	{"51", "55", BrandMastercard, []int{16}},
+	{"62", "62", BrandUnionPay, lengths16to19},
+	{"65", "65", BrandDiscover, lengths16to19},
+
+	{"4", "4", BrandVisa, []int{13, 16, 19}},
+}
+
+func lookupBin(number string) (binRange, bool) {
+	for _, r := range binRanges {
+		if len(number) < len(r.low) {
+			continue
+		}
+		prefix := number[:len(r.low)]
+		if prefix >= r.low && prefix <= r.high {
+			return r, true
+		}
+	}
+
+	return binRange{brand: BrandUnknown, lengths: lengths12to19}, false
+}
@@ -1,6 +1,126 @@
 package model
 
+import (
+	"atm/pkg/errorcode"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Card struct {
 	HolderName string `json:"holderName"`
 	Number     string `json:"number"`
+	// Expiry is the expiry date as YYMM. The card is valid until the end of
+	// that month.
+	Expiry      string `json:"expiry,omitempty"`
+	ServiceCode string `json:"serviceCode,omitempty"`
+}
+
+// Brand returns the card scheme identified by the number's IIN.
+func (c Card) Brand() Brand {
+	r, _ := lookupBin(c.Number)
+	return r.brand
+}
+
+// LuhnValid reports whether the number passes the Luhn (mod 10) check.
+func (c Card) LuhnValid() bool {
+	return Luhn(c.Number)
+}
+
+// ExpiresAt returns the first instant after the card's expiry month.
+func (c Card) ExpiresAt() (time.Time, error) {
+	if len(c.Expiry) != 4 || !isDigits(c.Expiry) {
+		return time.Time{}, errorcode.Wrap(errorcode.InvalidCardExpiry, fmt.Errorf("%q", c.Expiry))
+	}
+
+	year, _ := strconv.Atoi(c.Expiry[:2])
+	month, _ := strconv.Atoi(c.Expiry[2:])
+	if month < 1 || month > 12 {
+		return time.Time{}, errorcode.Wrap(errorcode.InvalidCardExpiry, fmt.Errorf("%q", c.Expiry))
+	}
+
+	return time.Date(2000+year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// Validate checks the number's format, length for its brand and Luhn digit,
+// the service code and, if the card carries one, its expiry date.
+func (c Card) Validate(now time.Time) error {
+	if c.Number == "" || !isDigits(c.Number) {
+		return errorcode.ErrInvalidCardNumber
+	}
+
+	r, _ := lookupBin(c.Number)
+	if !slices.Contains(r.lengths, len(c.Number)) {
+		return errorcode.Wrap(errorcode.InvalidCardLength, fmt.Errorf("%s card with %d digits", r.brand, len(c.Number)))
+	}
+	if !Luhn(c.Number) {
+		return errorcode.ErrCardLuhnCheckFail
+	}
+
+	if c.ServiceCode != "" && (len(c.ServiceCode) != 3 || !isDigits(c.ServiceCode)) {
+		return errorcode.ErrInvalidServiceCode
+	}
+
+	if c.Expiry != "" {
+		expiresAt, err := c.ExpiresAt()
+		if err != nil {
+			return err
+		}
+		if !now.Before(expiresAt) {
+			return errorcode.ErrCardExpired
+		}
+	}
+
+	return nil
+}
+
+// HolderNameFromTrack converts the SURNAME/GIVEN NAME form used on magnetic
+// stripes and chips into GIVEN NAME SURNAME.
+func HolderNameFromTrack(name string) string {
+	name = strings.TrimSpace(name)
+	surname, given, ok := strings.Cut(name, "/")
+	if !ok {
+		return name
+	}
+	surname, given = strings.TrimSpace(surname), strings.TrimSpace(given)
+	if given == "" {
+		return surname
+	}
+
+	return given + " " + surname
+}
+
+// Luhn reports whether number, a string of digits, has a valid mod 10 check digit.
+func Luhn(number string) bool {
+	if number == "" || !isDigits(number) {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func isDigits(s string) bool {
+	for i := range s {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// MaskedNumber returns the card number with all but the first six and last
+// four digits replaced by '*'. Numbers too short to keep six and four digits
+// apart show only the last four.
+func (c Card) MaskedNumber() string {
+	n := len(c.Number)
+	switch {
+	case n == 0:
+		return ""
+	case n <= 4:
+		return strings.Repeat("*", n)
+	case n < 6+4+1:
+		return strings.Repeat("*", n-4) + c.Number[n-4:]
+	default:
+		return c.Number[:6] + strings.Repeat("*", n-10) + c.Number[n-4:]
+	}
+}
+
+// String renders the card with its number masked.
+func (c Card) String() string {
+	return fmt.Sprintf("{HolderName:%s Number:%s Expiry:%s ServiceCode:%s}", c.HolderName, c.MaskedNumber(), c.Expiry, c.ServiceCode)
+}
+
+// Format makes every fmt verb, including %#v and %+v, use String so that the
+// full number is never printed.
+func (c Card) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", c.String())
+		return
+	}
+
+	_, _ = f.Write([]byte(c.String()))
+}
+
+func (c Card) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("holderName", c.HolderName),
+		slog.String("number", c.MaskedNumber()),
+		slog.String("brand", string(c.Brand())),
+	)
+}
+
+// MarshalJSON writes the card with its number masked. Use Sensitive to
+// serialise the full number deliberately, e.g. in a request to the issuer.
+func (c Card) MarshalJSON() ([]byte, error) {
+	redacted := SensitiveCard(c)
+	redacted.Number = c.MaskedNumber()
+
+	return json.Marshal(redacted)
+}
+
+// SensitiveCard is a Card whose JSON and fmt output include the full number.
+type SensitiveCard Card
+
+func (c Card) Sensitive() SensitiveCard {
+	return SensitiveCard(c)
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+const testPan = "4111111111111111"
+
+func TestMaskedNumber(t *testing.T) {
+	for number, masked := range map[string]string{
+		"":                    "",
+		"1234":                "****",
+		"4111111111":          "******1111",
+		"378282246310005":     "378282*****0005",
+		testPan:               "411111******1111",
+		"5061000000000000000": "506100*********0000",
+	} {
+		require.Equal(t, masked, Card{Number: number}.MaskedNumber(), number)
+	}
+}
+
+func TestFormatNeverPrintsPan(t *testing.T) {
+	card := Card{HolderName: "test user", Number: testPan, Expiry: "2512"}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		out := fmt.Sprintf(verb, card)
+		require.NotContains(t, out, testPan, verb)
+		require.NotContains(t, out, fmt.Sprintf("%x", testPan), verb)
+	}
+	require.Contains(t, card.String(), "411111******1111")
+
+	out := fmt.Sprintf("%+v", struct{ Card *Card }{&card})
+	require.NotContains(t, out, testPan)
+	out = fmt.Sprintf("%v", []Card{card})
+	require.NotContains(t, out, testPan)
+}
+
+func TestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	logger.Info("card inserted", "card", Card{HolderName: "test user", Number: testPan})
+	require.NotContains(t, buf.String(), testPan)
+	require.Contains(t, buf.String(), `"number":"411111******1111"`)
+	require.Contains(t, buf.String(), `"brand":"visa"`)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	card := Card{HolderName: "test user", Number: testPan, Expiry: "2512"}
+
+	out, err := json.Marshal(card)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"holderName":"test user","number":"411111******1111","expiry":"2512"}`, string(out))
+
+	out, err = json.Marshal(map[string]any{"card": &card})
+	require.NoError(t, err)
+	require.NotContains(t, string(out), testPan)
+
+	out, err = json.Marshal(card.Sensitive())
+	require.NoError(t, err)
+	require.Contains(t, string(out), testPan)
+
+	var decoded Card
+	require.NoError(t, json.Unmarshal([]byte(`{"holderName":"test user","number":"4111111111111111"}`), &decoded))
+	require.Equal(t, testPan, decoded.Number)
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"atm/pkg/errorcode"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLuhn(t *testing.T) {
+	require.True(t, Luhn("4111111111111111"))
+	require.True(t, Luhn("79927398713"))
+	require.False(t, Luhn("4111111111111112"))
+	require.False(t, Luhn(""))
+	require.False(t, Luhn("4111-1111"))
+}
+
+func TestBrand(t *testing.T) {
+	for number, brand := range map[string]Brand{
+		"4111111111111111":    BrandVisa,
+		"5500000000000004":    BrandMastercard,
+		"2223000048400011":    BrandMastercard,
+		"378282246310005":     BrandAmex,
+		"6221260000000000":    BrandDiscover,
+		"6011111111111117":    BrandDiscover,
+		"6200000000000005":    BrandUnionPay,
+		"3530111333300000":    BrandJCB,
+		"30569309025904":      BrandDiners,
+		"6759649826438453":    BrandMaestro,
+		"2200000000000004":    BrandMir,
+		"9792000000000001":    BrandTroy,
+		"5061000000000000000": BrandVerve,
+		"1234567890123":       BrandUnknown,
+	} {
+		require.Equal(t, brand, Card{Number: number}.Brand(), number)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, Card{Number: "4111111111111111"}.Validate(now))
+	require.NoError(t, Card{Number: "378282246310005", Expiry: "2406", ServiceCode: "201"}.Validate(now))
+
+	for card, expected := range map[Card]error{
+		{Number: ""}:                                     errorcode.ErrInvalidCardNumber,
+		{Number: "4111 1111 1111 1111"}:                  errorcode.ErrInvalidCardNumber,
+		{Number: "41111111111111"}:                       errorcode.ErrInvalidCardLength,
+		{Number: "37828224631000"}:                       errorcode.ErrInvalidCardLength,
+		{Number: "4111111111111112"}:                     errorcode.ErrCardLuhnCheckFail,
+		{Number: "4111111111111111", Expiry: "2405"}:     errorcode.ErrCardExpired,
+		{Number: "4111111111111111", Expiry: "2413"}:     errorcode.ErrInvalidCardExpiry,
+		{Number: "4111111111111111", Expiry: "24-6"}:     errorcode.ErrInvalidCardExpiry,
+		{Number: "4111111111111111", ServiceCode: "20"}:  errorcode.ErrInvalidServiceCode,
+		{Number: "4111111111111111", ServiceCode: "2x1"}: errorcode.ErrInvalidServiceCode,
+	} {
+		require.ErrorIs(t, card.Validate(now), expected, card.Number)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	expiresAt, err := Card{Expiry: "2412"}.ExpiresAt()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), expiresAt)
+}
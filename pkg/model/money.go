@@ -0,0 +1,146 @@
+package model
+
+import (
+	"atm/pkg/errorcode"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 alphabetic currency code.
+type Currency string
+
+// currencyExponents is the number of minor-unit digits of each supported
+// currency, per ISO 4217.
+var currencyExponents = map[Currency]int{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// Exponent returns the number of minor-unit digits of c.
+func (c Currency) Exponent() (int, error) {
+	exp, ok := currencyExponents[c]
+	if !ok {
+		return 0, errorcode.Wrap(errorcode.UnknownCurrency, fmt.Errorf("%q", string(c)))
+	}
+
+	return exp, nil
+}
+
+// Money is an amount in the minor units of its currency, e.g. cents for USD.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func NewMoney(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, errorcode.Wrap(errorcode.AmountOverflow, fmt.Errorf("%s + %s", m, o))
+	}
+
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	if (o.Amount < 0 && m.Amount > math.MaxInt64+o.Amount) || (o.Amount > 0 && m.Amount < math.MinInt64+o.Amount) {
+		return Money{}, errorcode.Wrap(errorcode.AmountOverflow, fmt.Errorf("%s - %s", m, o))
+	}
+
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul returns m * n.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount != 0 && n != 0 {
+		product := m.Amount * n
+		if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+			return Money{}, errorcode.Wrap(errorcode.AmountOverflow, fmt.Errorf("%s * %d", m, n))
+		}
+	}
+
+	return Money{Amount: m.Amount * n, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 as m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return errorcode.Wrap(errorcode.CurrencyMismatch, fmt.Errorf("%s and %s", m.Currency, o.Currency))
+	}
+
+	return nil
+}
+
+// String formats m with its currency's exponent, e.g. "12.34 USD" or
+// "1200 JPY". Unknown currencies are shown in minor units.
+func (m Money) String() string {
+	exp, err := m.Currency.Exponent()
+	if err != nil || exp == 0 {
+		return strconv.FormatInt(m.Amount, 10) + " " + string(m.Currency)
+	}
+
+	sign := ""
+	abs := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-(m.Amount + 1)) + 1
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:] + " " + string(m.Currency)
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"atm/pkg/errorcode"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestMoneyString(t *testing.T) {
+	for m, s := range map[Money]string{
+		NewMoney(1234, "USD"):          "12.34 USD",
+		NewMoney(5, "USD"):             "0.05 USD",
+		NewMoney(-5, "EUR"):            "-0.05 EUR",
+		NewMoney(0, "GBP"):             "0.00 GBP",
+		NewMoney(1200, "JPY"):          "1200 JPY",
+		NewMoney(1234, "KWD"):          "1.234 KWD",
+		NewMoney(math.MinInt64, "USD"): "-92233720368547758.08 USD",
+		NewMoney(42, "XXX"):            "42 XXX",
+	} {
+		require.Equal(t, s, m.String())
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(150, "USD").Add(NewMoney(75, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(225, "USD"), sum)
+
+	diff, err := NewMoney(150, "USD").Sub(NewMoney(200, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-50, "USD"), diff)
+	require.True(t, diff.IsNegative())
+
+	product, err := NewMoney(150, "USD").Mul(3)
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(450, "USD"), product)
+
+	cmp, err := NewMoney(150, "USD").Cmp(NewMoney(200, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, -1, cmp)
+
+	_, err = NewMoney(150, "USD").Add(NewMoney(1, "EUR"))
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+	_, err = NewMoney(150, "USD").Cmp(NewMoney(1, "EUR"))
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	_, err := NewMoney(math.MaxInt64, "USD").Add(NewMoney(1, "USD"))
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+	_, err = NewMoney(math.MinInt64, "USD").Add(NewMoney(-1, "USD"))
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+	_, err = NewMoney(math.MinInt64, "USD").Sub(NewMoney(1, "USD"))
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+	_, err = NewMoney(0, "USD").Sub(NewMoney(math.MinInt64, "USD"))
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+	_, err = NewMoney(math.MaxInt64/2+1, "USD").Mul(2)
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+	_, err = NewMoney(math.MinInt64, "USD").Mul(-1)
+	require.ErrorIs(t, err, errorcode.ErrAmountOverflow)
+
+	sum, err := NewMoney(math.MaxInt64-1, "USD").Add(NewMoney(1, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64), sum.Amount)
+}
+
+func TestCurrencyExponent(t *testing.T) {
+	exp, err := Currency("JPY").Exponent()
+	require.NoError(t, err)
+	require.Equal(t, 0, exp)
+
+	_, err = Currency("XXX").Exponent()
+	require.ErrorIs(t, err, errorcode.ErrUnknownCurrency)
+}
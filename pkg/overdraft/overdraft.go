@@ -0,0 +1,103 @@
+// Package overdraft describes how far a withdrawal may take an account past
+// its balance. Account services evaluate a Policy when they debit an account
+// so that the check and the debit happen in one step.
+package overdraft
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+)
+
+type Kind int
+
+const (
+	// None refuses any debit larger than the balance.
+	None Kind = iota
+	// FixedLimit lets the balance go down to minus Limit.
+	FixedLimit
+	// LinkedSweep covers a shortfall from the linked savings account.
+	LinkedSweep
+)
+
+func (k Kind) String() string {
+	switch k {
+	case None:
+		return "none"
+	case FixedLimit:
+		return "fixed limit"
+	case LinkedSweep:
+		return "linked sweep"
+	default:
+		return "unknown"
+	}
+}
+
+type Policy struct {
+	Kind Kind
+	// Limit is how far below zero a FixedLimit policy lets the balance go.
+	Limit model.Money
+	// LinkedAccountID is the account a LinkedSweep policy draws from.
+	LinkedAccountID string
+}
+
+// Decide checks a debit of amount from an account holding balance. linked
+// is the balance of the linked account and is only used by LinkedSweep.
+// It returns how much has to be swept from the linked account before the
+// debit, or an error wrapping errorcode.ErrIsOverdraw if the policy does
+// not cover the debit.
+func (p Policy) Decide(balance, linked, amount model.Money) (model.Money, error) {
+	shortfall, err := amount.Sub(balance)
+	if err != nil {
+		return model.Money{}, err
+	}
+	if !shortfall.IsPositive() {
+		return model.Money{}, nil
+	}
+
+	var cover model.Money
+	switch p.Kind {
+	case None:
+	case FixedLimit:
+		cover = p.Limit
+	case LinkedSweep:
+		if p.LinkedAccountID == "" {
+			return model.Money{}, errorcode.Wrap(errorcode.IsOverdraw, fmt.Errorf("no linked account"))
+		}
+		cover = linked
+	default:
+		return model.Money{}, errorcode.Wrap(errorcode.IsOverdraw, fmt.Errorf("unknown policy %d", p.Kind))
+	}
+
+	overdrawn := errorcode.Wrap(errorcode.IsOverdraw, fmt.Errorf("short by %s under %s policy", shortfall, p.Kind))
+	if cover.IsZero() {
+		return model.Money{}, overdrawn
+	}
+	cmp, err := shortfall.Cmp(cover)
+	if err != nil {
+		return model.Money{}, err
+	}
+	if cmp > 0 {
+		return model.Money{}, overdrawn
+	}
+	if p.Kind == LinkedSweep {
+		return shortfall, nil
+	}
+
+	return model.Money{}, nil
+}
+
+// Policies picks the policy for each account.
+type Policies struct {
+	// Default applies to accounts with no entry in Accounts.
+	Default  Policy
+	Accounts map[string]Policy
+}
+
+func (p Policies) For(accountID string) Policy {
+	if policy, ok := p.Accounts[accountID]; ok {
+		return policy
+	}
+
+	return p.Default
+}
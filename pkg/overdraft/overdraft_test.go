@@ -0,0 +1,57 @@
+package overdraft
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+func TestDecide(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   Policy
+		balance  model.Money
+		linked   model.Money
+		amount   model.Money
+		sweep    model.Money
+		expected error
+	}{
+		{name: "covered by balance", policy: Policy{}, balance: usd(5000), amount: usd(5000)},
+		{name: "none", policy: Policy{}, balance: usd(5000), amount: usd(5001), expected: errorcode.ErrIsOverdraw},
+		{name: "within limit", policy: Policy{Kind: FixedLimit, Limit: usd(1000)}, balance: usd(5000), amount: usd(6000)},
+		{name: "above limit", policy: Policy{Kind: FixedLimit, Limit: usd(1000)}, balance: usd(5000), amount: usd(6001), expected: errorcode.ErrIsOverdraw},
+		{name: "already overdrawn", policy: Policy{Kind: FixedLimit, Limit: usd(1000)}, balance: usd(-800), amount: usd(500), expected: errorcode.ErrIsOverdraw},
+		{name: "zero limit", policy: Policy{Kind: FixedLimit}, balance: usd(0), amount: usd(1), expected: errorcode.ErrIsOverdraw},
+		{name: "sweep", policy: Policy{Kind: LinkedSweep, LinkedAccountID: "savings"}, balance: usd(5000), linked: usd(3000), amount: usd(7000), sweep: usd(2000)},
+		{name: "sweep not needed", policy: Policy{Kind: LinkedSweep, LinkedAccountID: "savings"}, balance: usd(5000), linked: usd(3000), amount: usd(4000)},
+		{name: "sweep short", policy: Policy{Kind: LinkedSweep, LinkedAccountID: "savings"}, balance: usd(5000), linked: usd(3000), amount: usd(8001), expected: errorcode.ErrIsOverdraw},
+		{name: "no linked account", policy: Policy{Kind: LinkedSweep}, balance: usd(5000), amount: usd(6000), expected: errorcode.ErrIsOverdraw},
+		{name: "currency", policy: Policy{}, balance: usd(5000), amount: model.NewMoney(100, "EUR"), expected: errorcode.ErrCurrencyMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sweep, err := tc.policy.Decide(tc.balance, tc.linked, tc.amount)
+			if tc.expected != nil {
+				require.ErrorIs(t, err, tc.expected)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.sweep, sweep)
+		})
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	p := Policies{
+		Default:  Policy{Kind: FixedLimit, Limit: usd(1000)},
+		Accounts: map[string]Policy{"current": {Kind: LinkedSweep, LinkedAccountID: "savings"}},
+	}
+
+	require.Equal(t, LinkedSweep, p.For("current").Kind)
+	require.Equal(t, FixedLimit, p.For("other").Kind)
+	require.Equal(t, None, Policies{}.For("current").Kind)
+}
@@ -0,0 +1,326 @@
+// Package pinblock encodes and encrypts PINs as ISO 9564-1 PIN blocks.
+//
+// Formats 0, 1 and 3 produce 8-byte blocks encrypted under a double or
+// triple length TDES key. Format 4 produces 16-byte blocks encrypted under
+// an AES key.
+package pinblock
+
+import (
+	"atm/pkg/errorcode"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+type Format int
+
+const (
+	Format0 Format = 0
+	Format1 Format = 1
+	Format3 Format = 3
+	Format4 Format = 4
+)
+
+const (
+	minPinLength = 4
+	maxPinLength = 12
+)
+
+// Cipher encrypts and decrypts PIN blocks of one format under one PIN key.
+type Cipher struct {
+	format Format
+	block  cipher.Block
+	rand   io.Reader
+}
+
+// NewCipher returns a Cipher for format using key. Formats 0, 1 and 3 need a
+// 16 or 24 byte TDES key, format 4 a 16, 24 or 32 byte AES key.
+func NewCipher(format Format, key []byte) (*Cipher, error) {
+	var (
+		block cipher.Block
+		err   error
+	)
+
+	switch format {
+	case Format0, Format1, Format3:
+		switch len(key) {
+		case 16:
+			key = append(append(make([]byte, 0, 24), key...), key[:8]...)
+		case 24:
+		default:
+			return nil, errorcode.Wrap(errorcode.InvalidPinKey, fmt.Errorf("tdes key length %d", len(key)))
+		}
+		block, err = des.NewTripleDESCipher(key)
+	case Format4:
+		block, err = aes.NewCipher(key)
+	default:
+		return nil, errorcode.Wrap(errorcode.UnsupportedPinBlockFormat, fmt.Errorf("format %d", format))
+	}
+	if err != nil {
+		return nil, errorcode.Wrap(errorcode.InvalidPinKey, err)
+	}
+
+	return &Cipher{format: format, block: block, rand: rand.Reader}, nil
+}
+
+func (c *Cipher) Format() Format {
+	return c.format
+}
+
+// Encrypt returns the encrypted PIN block for pin and pan.
+func (c *Cipher) Encrypt(pin, pan string) ([]byte, error) {
+	if err := checkPin(pin); err != nil {
+		return nil, err
+	}
+
+	if c.format == Format4 {
+		return c.encrypt4(pin, pan)
+	}
+
+	clear, err := c.encode(pin, pan)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(clear))
+	c.block.Encrypt(out, clear)
+
+	return out, nil
+}
+
+// Decrypt recovers the clear PIN from an encrypted PIN block.
+func (c *Cipher) Decrypt(block []byte, pan string) (string, error) {
+	if len(block) != c.block.BlockSize() {
+		return "", errorcode.Wrap(errorcode.InvalidPinBlock, fmt.Errorf("block length %d", len(block)))
+	}
+
+	if c.format == Format4 {
+		return c.decrypt4(block, pan)
+	}
+
+	clear := make([]byte, len(block))
+	c.block.Decrypt(clear, block)
+
+	return c.decode(clear, pan)
+}
+
+// encode builds the clear 8-byte block for formats 0, 1 and 3.
+func (c *Cipher) encode(pin, pan string) ([]byte, error) {
+	field := make([]byte, 16)
+	field[0] = byte(c.format)
+	field[1] = byte(len(pin))
+	for i := range pin {
+		field[2+i] = pin[i] - '0'
+	}
+
+	fill, err := c.fill(16 - 2 - len(pin))
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range fill {
+		switch c.format {
+		case Format0:
+			n = 0xF
+		case Format3:
+			n = 0xA + n%6
+		}
+		field[2+len(pin)+i] = n
+	}
+	pinField := pack(field)
+
+	if c.format == Format1 {
+		return pinField, nil
+	}
+
+	panField, err := panField03(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	return xor(pinField, panField), nil
+}
+
+func (c *Cipher) decode(clear []byte, pan string) (string, error) {
+	pinField := clear
+	if c.format != Format1 {
+		panField, err := panField03(pan)
+		if err != nil {
+			return "", err
+		}
+		pinField = xor(clear, panField)
+	}
+
+	nibbles := unpack(pinField)
+	if Format(nibbles[0]) != c.format {
+		return "", errorcode.Wrap(errorcode.InvalidPinBlock, fmt.Errorf("control field %X", nibbles[0]))
+	}
+
+	return pinDigits(nibbles)
+}
+
+func (c *Cipher) encrypt4(pin, pan string) ([]byte, error) {
+	field := make([]byte, 32)
+	field[0] = byte(Format4)
+	field[1] = byte(len(pin))
+	for i := range pin {
+		field[2+i] = pin[i] - '0'
+	}
+	for i := 2 + len(pin); i < 16; i++ {
+		field[i] = 0xA
+	}
+	fill, err := c.fill(16)
+	if err != nil {
+		return nil, err
+	}
+	copy(field[16:], fill)
+
+	panField, err := panField4(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 16)
+	c.block.Encrypt(out, pack(field))
+	c.block.Encrypt(out, xor(out, panField))
+
+	return out, nil
+}
+
+func (c *Cipher) decrypt4(block []byte, pan string) (string, error) {
+	panField, err := panField4(pan)
+	if err != nil {
+		return "", err
+	}
+
+	out := make([]byte, 16)
+	c.block.Decrypt(out, block)
+	c.block.Decrypt(out, xor(out, panField))
+
+	nibbles := unpack(out)
+	if Format(nibbles[0]) != Format4 {
+		return "", errorcode.Wrap(errorcode.InvalidPinBlock, fmt.Errorf("control field %X", nibbles[0]))
+	}
+
+	return pinDigits(nibbles[:16])
+}
+
+// fill returns n random nibbles.
+func (c *Cipher) fill(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.rand, buf); err != nil {
+		return nil, err
+	}
+	for i := range buf {
+		buf[i] &= 0xF
+	}
+
+	return buf, nil
+}
+
+func checkPin(pin string) error {
+	if len(pin) < minPinLength || len(pin) > maxPinLength || !isDigits(pin) {
+		return errorcode.ErrInvalidPinFormat
+	}
+
+	return nil
+}
+
+// panField03 is the PAN field for formats 0 and 3: four zero nibbles then the
+// rightmost twelve PAN digits excluding the check digit.
+func panField03(pan string) ([]byte, error) {
+	if len(pan) < 2 || !isDigits(pan) {
+		return nil, errorcode.ErrInvalidPan
+	}
+
+	digits := pan[:len(pan)-1]
+	if len(digits) > 12 {
+		digits = digits[len(digits)-12:]
+	}
+
+	field := make([]byte, 16)
+	offset := 16 - len(digits)
+	for i := range digits {
+		field[offset+i] = digits[i] - '0'
+	}
+
+	return pack(field), nil
+}
+
+// panField4 is the PAN field for format 4: the PAN length minus twelve, then
+// the PAN left padded to twelve digits and right padded with zeros.
+func panField4(pan string) ([]byte, error) {
+	if len(pan) == 0 || len(pan) > 19 || !isDigits(pan) {
+		return nil, errorcode.ErrInvalidPan
+	}
+
+	field := make([]byte, 32)
+	offset := 1
+	if len(pan) < 12 {
+		offset += 12 - len(pan)
+	} else {
+		field[0] = byte(len(pan) - 12)
+	}
+	for i := range pan {
+		field[offset+i] = pan[i] - '0'
+	}
+
+	return pack(field), nil
+}
+
+func pinDigits(nibbles []byte) (string, error) {
+	n := int(nibbles[1])
+	if n < minPinLength || n > maxPinLength {
+		return "", errorcode.Wrap(errorcode.InvalidPinBlock, fmt.Errorf("pin length %d", n))
+	}
+
+	pin := make([]byte, n)
+	for i := range pin {
+		d := nibbles[2+i]
+		if d > 9 {
+			return "", errorcode.Wrap(errorcode.InvalidPinBlock, fmt.Errorf("pin digit %X", d))
+		}
+		pin[i] = '0' + d
+	}
+
+	return string(pin), nil
+}
+
+func isDigits(s string) bool {
+	for i := range s {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func pack(nibbles []byte) []byte {
+	out := make([]byte, len(nibbles)/2)
+	for i := range out {
+		out[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+
+	return out
+}
+
+func unpack(b []byte) []byte {
+	out := make([]byte, 2*len(b))
+	for i, v := range b {
+		out[2*i] = v >> 4
+		out[2*i+1] = v & 0xF
+	}
+
+	return out
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+
+	return out
+}
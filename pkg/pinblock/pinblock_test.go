@@ -0,0 +1,110 @@
+package pinblock
+
+import (
+	"atm/pkg/errorcode"
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var (
+	tdesKey = bytes.Repeat([]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF}, 2)
+	aesKey  = bytes.Repeat([]byte{0x0F, 0x1E, 0x2D, 0x3C}, 4)
+)
+
+func TestEncodeFormat0(t *testing.T) {
+	c, err := NewCipher(Format0, tdesKey)
+	require.NoError(t, err)
+
+	clear, err := c.encode("1234", "43219876543210987")
+	require.NoError(t, err)
+	require.Equal(t, "0412AC89ABCDEF67", hexUpper(clear))
+}
+
+func TestEncodeFormat3Fill(t *testing.T) {
+	c, err := NewCipher(Format3, tdesKey)
+	require.NoError(t, err)
+
+	clear, err := c.encode("1234", "43219876543210987")
+	require.NoError(t, err)
+
+	panField, _ := panField03("43219876543210987")
+	nibbles := unpack(xor(clear, panField))
+	require.Equal(t, []byte{3, 4, 1, 2, 3, 4}, nibbles[:6])
+	for _, n := range nibbles[6:] {
+		require.GreaterOrEqual(t, n, byte(0xA))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		format Format
+		key    []byte
+	}{
+		{Format0, tdesKey},
+		{Format1, tdesKey},
+		{Format3, tdesKey},
+		{Format3, append(tdesKey, tdesKey[:8]...)},
+		{Format4, aesKey},
+	} {
+		c, err := NewCipher(tc.format, tc.key)
+		require.NoError(t, err)
+		require.Equal(t, tc.format, c.Format())
+
+		for _, pin := range []string{"1234", "987654", "123456789012"} {
+			block, err := c.Encrypt(pin, "4111111111111111")
+			require.NoError(t, err)
+			require.Len(t, block, c.block.BlockSize())
+			require.NotContains(t, hexUpper(block), pin)
+
+			got, err := c.Decrypt(block, "4111111111111111")
+			require.NoError(t, err)
+			require.Equal(t, pin, got)
+		}
+	}
+}
+
+func TestFormat4BoundToPan(t *testing.T) {
+	c, err := NewCipher(Format4, aesKey)
+	require.NoError(t, err)
+	c.rand = bytes.NewReader(make([]byte, 16))
+
+	block, err := c.Encrypt("1234", "4111111111111111")
+	require.NoError(t, err)
+
+	_, err = c.Decrypt(block, "5500000000000004")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinBlock)
+}
+
+func TestRandomFill(t *testing.T) {
+	c, err := NewCipher(Format4, aesKey)
+	require.NoError(t, err)
+
+	first, _ := c.Encrypt("1234", "4111111111111111")
+	second, _ := c.Encrypt("1234", "4111111111111111")
+	require.NotEqual(t, first, second)
+}
+
+func TestInvalidInput(t *testing.T) {
+	_, err := NewCipher(Format0, aesKey[:8])
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinKey)
+	_, err = NewCipher(Format4, tdesKey[:10])
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinKey)
+	_, err = NewCipher(Format(2), tdesKey)
+	require.ErrorIs(t, err, errorcode.ErrUnsupportedPinBlockFormat)
+
+	c, _ := NewCipher(Format0, tdesKey)
+	_, err = c.Encrypt("12a4", "4111111111111111")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinFormat)
+	_, err = c.Encrypt("123", "4111111111111111")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinFormat)
+	_, err = c.Encrypt("1234", "4111-1111")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPan)
+	_, err = c.Decrypt([]byte{1, 2, 3}, "4111111111111111")
+	require.ErrorIs(t, err, errorcode.ErrInvalidPinBlock)
+}
+
+func hexUpper(b []byte) string {
+	return string(bytes.ToUpper([]byte(hex.EncodeToString(b))))
+}
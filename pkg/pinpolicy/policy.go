@@ -0,0 +1,58 @@
+// Package pinpolicy decides whether a customer-chosen PIN is acceptable.
+package pinpolicy
+
+import (
+	"atm/pkg/errorcode"
+	"fmt"
+)
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// AllowTrivial permits PINs made of one repeated digit or of a run of
+	// consecutive digits such as 1234 or 9876.
+	AllowTrivial bool
+}
+
+func Default() Policy {
+	return Policy{MinLength: 4, MaxLength: 6}
+}
+
+// Validate returns an error carrying errorcode.NewPinLength or
+// errorcode.NewPinTooWeak if pin does not satisfy the policy.
+func (p Policy) Validate(pin string) error {
+	if len(pin) < p.MinLength || len(pin) > p.MaxLength {
+		return errorcode.Wrap(errorcode.NewPinLength, fmt.Errorf("want %d to %d digits, got %d", p.MinLength, p.MaxLength, len(pin)))
+	}
+	for i := range pin {
+		if pin[i] < '0' || pin[i] > '9' {
+			return errorcode.ErrInvalidPinFormat
+		}
+	}
+
+	if !p.AllowTrivial && IsTrivial(pin) {
+		return errorcode.ErrNewPinTooWeak
+	}
+
+	return nil
+}
+
+// IsTrivial reports whether pin repeats a single digit or steps through
+// consecutive digits in either direction.
+func IsTrivial(pin string) bool {
+	if len(pin) < 2 {
+		return true
+	}
+
+	step := int(pin[1]) - int(pin[0])
+	if step < -1 || step > 1 {
+		return false
+	}
+	for i := 2; i < len(pin); i++ {
+		if int(pin[i])-int(pin[i-1]) != step {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,26 @@
+package pinpolicy
+
+import (
+	"atm/pkg/errorcode"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	p := Default()
+
+	require.NoError(t, p.Validate("2580"))
+	require.NoError(t, p.Validate("135791"))
+	require.NoError(t, p.Validate("1123"))
+
+	require.ErrorIs(t, p.Validate("258"), errorcode.ErrNewPinLength)
+	require.ErrorIs(t, p.Validate("2580123"), errorcode.ErrNewPinLength)
+	require.ErrorIs(t, p.Validate("25a0"), errorcode.ErrInvalidPinFormat)
+
+	for _, pin := range []string{"0000", "1111", "1234", "4321", "345678", "9876"} {
+		require.ErrorIs(t, p.Validate(pin), errorcode.ErrNewPinTooWeak, pin)
+	}
+
+	p.AllowTrivial = true
+	require.NoError(t, p.Validate("1234"))
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"atm/pkg/acceptor"
+	"context"
+)
+
+// AcceptorInterface drives the cash deposit acceptor hardware.
+// Implementations must return promptly once ctx is done.
+type AcceptorInterface interface {
+	// Accept takes the notes the customer inserted and counts them. Genuine
+	// notes are held in escrow; unrecognised and suspect notes are handed
+	// back at once.
+	Accept(ctx context.Context) (acceptor.Bundle, error)
+	// Stack moves the notes in escrow into the cash box.
+	Stack(ctx context.Context) error
+	// Return hands the notes in escrow back to the customer.
+	Return(ctx context.Context) error
+}
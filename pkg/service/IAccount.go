@@ -1,6 +1,9 @@
 package service
 
-import "atm/pkg/model"
+import (
+	"atm/pkg/model"
+	"time"
+)
 
 type AccountInterface interface {
 	EnterPinNumber(card model.Card, number string) (bool, error)
@@ -12,3 +15,55 @@ type AccountInterface interface {
 	MakeDeposit(accountID string, deposit int) (int, error)
 	Withdraw(accountID string, withdrawAmount int) (int, error)
 }
+
+// PinChanger may be implemented by a legacy AccountInterface that can change
+// PINs. AdaptAccount forwards ChangePin to it when available.
+type PinChanger interface {
+	ChangePinNumber(card model.Card, newNumber string) error
+}
+
+// Reverser may be implemented by a legacy AccountInterface that can reverse
+// undelivered withdrawals. AdaptAccount forwards Reverse to it when available.
+type Reverser interface {
+	ReverseWithdrawal(accountID string, amount int) (int, error)
+}
+
+// ChequeDepositor may be implemented by a legacy AccountInterface that
+// accepts cheques. AdaptAccount forwards DepositCheque to it when available.
+// micrLine is in the form returned by micr.Line.String and the returned
+// string is the hold ID.
+type ChequeDepositor interface {
+	DepositCheque(accountID string, micrLine string, amount int) (string, error)
+}
+
+// FeeCharger may be implemented by a legacy AccountInterface that can post
+// fees. AdaptAccount forwards ChargeFee to it when available.
+type FeeCharger interface {
+	ChargeFee(accountID string, description string, amount int) (int, error)
+}
+
+// OverdraftDebiter may be implemented by a legacy AccountInterface that can
+// check and post a withdrawal in one step. AdaptAccount forwards
+// DebitWithCheck to it when available. The debit of amount must fail with
+// errorcode.ErrIsOverdraw unless amount plus fee is covered by the available
+// balance (ledger minus holds), overdraftLimit below zero or, if sweepFrom
+// is set, that account's balance.
+type OverdraftDebiter interface {
+	DebitWithCheck(accountID string, amount, fee, overdraftLimit int, sweepFrom string) (int, error)
+}
+
+// HoldKeeper may be implemented by a legacy AccountInterface that keeps
+// deposit holds. AdaptAccount forwards GetBalances and DepositWithHolds to
+// it when available. Otherwise GetBalances reports no holds and
+// DepositWithHolds only takes deposits without holds.
+type HoldKeeper interface {
+	GetBalances(accountID string) (ledger, available int, holds []LegacyHold, err error)
+	DepositWithHolds(accountID string, amount int, holds []LegacyHold) (int, error)
+}
+
+// LegacyHold is a Hold in a legacy service's int amounts.
+type LegacyHold struct {
+	ID        string
+	Amount    int
+	ReleaseAt time.Time
+}
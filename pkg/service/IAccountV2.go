@@ -0,0 +1,103 @@
+package service
+
+import (
+	"atm/pkg/micr"
+	"atm/pkg/model"
+	"atm/pkg/overdraft"
+	"atm/pkg/pinblock"
+	"context"
+	"time"
+)
+
+// AccountInterfaceV2 is the context-aware version of AccountInterface.
+// Implementations must return promptly once ctx is done.
+type AccountInterfaceV2 interface {
+	// VerifyPin checks an encrypted PIN block. The clear PIN never reaches
+	// the account service.
+	VerifyPin(ctx context.Context, req EncryptedPin) (bool, error)
+	// ChangePin replaces the card's PIN with the one in req.PinBlock.
+	ChangePin(ctx context.Context, req EncryptedPin) error
+
+	GetAccountIDs(ctx context.Context) ([]string, error)
+	SelectAccountID(ctx context.Context, accountID string) error
+
+	// GetBalance, MakeDeposit and Withdraw return the account balance after
+	// the operation, in the account's currency.
+	GetBalance(ctx context.Context, accountID string) (model.Money, error)
+	MakeDeposit(ctx context.Context, accountID string, deposit model.Money) (model.Money, error)
+	// GetBalances reports the ledger balance, the funds available for
+	// withdrawal and the holds that make up the difference.
+	GetBalances(ctx context.Context, accountID string) (Balances, error)
+	// DepositWithHolds credits deposit and holds back each of holds until
+	// its ReleaseAt, in one step. It returns the ledger balance after the
+	// deposit. The service assigns the hold IDs.
+	DepositWithHolds(ctx context.Context, accountID string, deposit model.Money, holds []Hold) (model.Money, error)
+	Withdraw(ctx context.Context, accountID string, withdrawAmount model.Money) (model.Money, error)
+	// DebitWithCheck checks debit against the available balance under
+	// debit.Policy and posts it in one step, so no other posting can change
+	// the balance in between. Funds on hold are never drawn on. It
+	// returns an error wrapping errorcode.ErrIsOverdraw if the policy does
+	// not cover the debit, and the balance after it otherwise.
+	DebitWithCheck(ctx context.Context, accountID string, debit Debit) (model.Money, error)
+	// Reverse credits back amount of an earlier withdrawal that was not
+	// delivered to the customer.
+	Reverse(ctx context.Context, accountID string, amount model.Money) (model.Money, error)
+	// DepositCheque places cheque.Amount on hold on the account until the
+	// cheque clears. The funds are not available until the hold is released.
+	DepositCheque(ctx context.Context, accountID string, cheque Cheque) (Hold, error)
+	// ChargeFee debits fee as its own statement line and returns the
+	// balance after it.
+	ChargeFee(ctx context.Context, accountID string, fee Fee) (model.Money, error)
+}
+
+// EncryptedPin is an ISO 9564 PIN block encrypted under the terminal's
+// PIN key, together with the PAN it was built from.
+type EncryptedPin struct {
+	PAN      string
+	Format   pinblock.Format
+	PinBlock []byte
+	// ChipData carries the card's ARQC data objects (see emv.ARQCTags) for
+	// chip transactions.
+	ChipData []byte
+}
+
+// Debit is a withdrawal checked against an overdraft policy.
+type Debit struct {
+	Amount model.Money
+	// Fee is checked together with Amount but not posted; it is posted
+	// with ChargeFee once the cash has been delivered.
+	Fee    model.Money
+	Policy overdraft.Policy
+}
+
+// Cheque is a cheque presented for deposit.
+type Cheque struct {
+	MICR micr.Line
+	// Amount is the amount the customer declared.
+	Amount model.Money
+}
+
+// Balances separates the ledger balance, which includes every posting,
+// from the available balance, which leaves out funds on hold.
+type Balances struct {
+	Ledger    model.Money
+	Available model.Money
+	Holds     []Hold
+}
+
+// Hold describes deposited funds that are not available yet, such as a
+// cheque that has not cleared.
+type Hold struct {
+	ID     string
+	Amount model.Money
+	// ReleaseAt is when the funds are expected to become available. It is
+	// zero if the service does not say.
+	ReleaseAt time.Time
+}
+
+// Fee is a surcharge posted separately from the transaction it is for.
+type Fee struct {
+	// Description is the statement text, e.g. "withdrawal fee".
+	Description string
+	Amount      model.Money
+}
@@ -8,3 +8,9 @@ type CardInterface interface {
 	InsertCard(card model.Card) error
 	RemoveCard() error
 }
+
+// CardRetainer may be implemented by a legacy CardInterface that can capture
+// cards. AdaptCard forwards RetainCard to it when available.
+type CardRetainer interface {
+	RetainCard() error
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"atm/pkg/model"
+	"context"
+)
+
+// CardInterfaceV2 is the context-aware version of CardInterface.
+// Implementations must return promptly once ctx is done.
+type CardInterfaceV2 interface {
+	InsertCard(ctx context.Context, card model.Card) error
+	RemoveCard(ctx context.Context) error
+	// RetainCard captures the inserted card instead of returning it.
+	RetainCard(ctx context.Context) error
+}
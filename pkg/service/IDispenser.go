@@ -0,0 +1,20 @@
+package service
+
+import (
+	"atm/pkg/dispenser"
+	"context"
+)
+
+// DispenserInterface drives the cash dispenser hardware. Implementations
+// must return promptly once ctx is done.
+type DispenserInterface interface {
+	// Dispense picks the notes in mix into the stacker and returns the notes
+	// actually picked, which is less than mix if the dispenser jams. Picked
+	// notes have left their cassettes whether or not an error is returned.
+	Dispense(ctx context.Context, mix dispenser.Mix) (dispenser.Mix, error)
+	// Present opens the shutter and waits for the customer. It reports
+	// whether the notes were taken; notes left past the device's timeout
+	// are retracted. If it returns an error, whether the customer got the
+	// notes is unknown.
+	Present(ctx context.Context) (bool, error)
+}
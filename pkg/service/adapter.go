@@ -0,0 +1,380 @@
+package service
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"atm/pkg/overdraft"
+	"atm/pkg/pinblock"
+	"context"
+	"fmt"
+)
+
+// AdaptAccount wraps a legacy AccountInterface so it satisfies AccountInterfaceV2.
+//
+// The legacy methods cannot be interrupted, so each call runs on its own
+// goroutine and the adapter returns ctx.Err() as soon as ctx is done. The
+// abandoned call is left to finish in the background and its result is dropped.
+//
+// Legacy services verify clear PINs, so the adapter decrypts PIN blocks with
+// opts.PinCipher before calling EnterPinNumber. The adapter therefore belongs
+// on the host side of the PIN key boundary.
+func AdaptAccount(svc AccountInterface, opts AccountAdapterOptions) AccountInterfaceV2 {
+	return &accountAdapter{svc: svc, pinCipher: opts.PinCipher, currency: opts.Currency, checkThenWithdraw: opts.CheckThenWithdraw}
+}
+
+// AccountAdapterOptions configures AdaptAccount.
+type AccountAdapterOptions struct {
+	// PinCipher decrypts PIN blocks for the legacy service. If nil, VerifyPin
+	// and ChangePin return errorcode.ErrUnsupported.
+	PinCipher *pinblock.Cipher
+	// Currency is the currency of the legacy service's int amounts, which
+	// are taken to be in its minor units. Amounts in any other currency are
+	// rejected with errorcode.ErrCurrencyMismatch.
+	Currency model.Currency
+	// CheckThenWithdraw lets DebitWithCheck fall back, for services that
+	// are not an OverdraftDebiter, to reading the available balance and
+	// then calling Withdraw. The check is not atomic: another posting
+	// between the two calls, such as a withdrawal at a second terminal,
+	// can overdraw the account. Only overdraft.None is supported. Without
+	// it, DebitWithCheck on such services returns errorcode.ErrUnsupported.
+	CheckThenWithdraw bool
+}
+
+// AdaptCard wraps a legacy CardInterface so it satisfies CardInterfaceV2.
+// See AdaptAccount for how cancellation is handled.
+func AdaptCard(svc CardInterface) CardInterfaceV2 {
+	return &cardAdapter{svc: svc}
+}
+
+type accountAdapter struct {
+	svc               AccountInterface
+	pinCipher         *pinblock.Cipher
+	currency          model.Currency
+	checkThenWithdraw bool
+}
+
+func (a *accountAdapter) VerifyPin(ctx context.Context, req EncryptedPin) (bool, error) {
+	if a.pinCipher == nil || a.pinCipher.Format() != req.Format {
+		return false, errorcode.ErrUnsupported
+	}
+
+	pin, err := a.pinCipher.Decrypt(req.PinBlock, req.PAN)
+	if err != nil {
+		return false, err
+	}
+
+	return call(ctx, func() (bool, error) {
+		return a.svc.EnterPinNumber(model.Card{Number: req.PAN}, pin)
+	})
+}
+
+func (a *accountAdapter) ChangePin(ctx context.Context, req EncryptedPin) error {
+	changer, ok := a.svc.(PinChanger)
+	if !ok || a.pinCipher == nil || a.pinCipher.Format() != req.Format {
+		return errorcode.ErrUnsupported
+	}
+
+	pin, err := a.pinCipher.Decrypt(req.PinBlock, req.PAN)
+	if err != nil {
+		return err
+	}
+
+	_, err = call(ctx, func() (struct{}, error) {
+		return struct{}{}, changer.ChangePinNumber(model.Card{Number: req.PAN}, pin)
+	})
+	return err
+}
+
+func (a *accountAdapter) GetAccountIDs(ctx context.Context) ([]string, error) {
+	return call(ctx, a.svc.GetAccountIDs)
+}
+
+func (a *accountAdapter) SelectAccountID(ctx context.Context, accountID string) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, a.svc.SelectAccountID(accountID)
+	})
+	return err
+}
+
+func (a *accountAdapter) GetBalance(ctx context.Context, accountID string) (model.Money, error) {
+	return a.money(call(ctx, func() (int, error) {
+		return a.svc.GetBalance(accountID)
+	}))
+}
+
+func (a *accountAdapter) MakeDeposit(ctx context.Context, accountID string, deposit model.Money) (model.Money, error) {
+	amount, err := a.toInt(deposit)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return a.svc.MakeDeposit(accountID, amount)
+	}))
+}
+
+func (a *accountAdapter) Withdraw(ctx context.Context, accountID string, withdrawAmount model.Money) (model.Money, error) {
+	amount, err := a.toInt(withdrawAmount)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return a.svc.Withdraw(accountID, amount)
+	}))
+}
+
+func (a *accountAdapter) GetBalances(ctx context.Context, accountID string) (Balances, error) {
+	keeper, ok := a.svc.(HoldKeeper)
+	if !ok {
+		balance, err := a.GetBalance(ctx, accountID)
+		if err != nil {
+			return Balances{}, err
+		}
+
+		return Balances{Ledger: balance, Available: balance}, nil
+	}
+
+	type result struct {
+		ledger, available int
+		holds             []LegacyHold
+	}
+	r, err := call(ctx, func() (result, error) {
+		ledger, available, holds, err := keeper.GetBalances(accountID)
+		return result{ledger: ledger, available: available, holds: holds}, err
+	})
+	if err != nil {
+		return Balances{}, err
+	}
+
+	balances := Balances{
+		Ledger:    model.NewMoney(int64(r.ledger), a.currency),
+		Available: model.NewMoney(int64(r.available), a.currency),
+	}
+	for _, h := range r.holds {
+		balances.Holds = append(balances.Holds, Hold{
+			ID:        h.ID,
+			Amount:    model.NewMoney(int64(h.Amount), a.currency),
+			ReleaseAt: h.ReleaseAt,
+		})
+	}
+
+	return balances, nil
+}
+
+func (a *accountAdapter) DepositWithHolds(ctx context.Context, accountID string, deposit model.Money, holds []Hold) (model.Money, error) {
+	keeper, ok := a.svc.(HoldKeeper)
+	if !ok {
+		if len(holds) > 0 {
+			return model.Money{}, errorcode.ErrUnsupported
+		}
+
+		return a.MakeDeposit(ctx, accountID, deposit)
+	}
+
+	amount, err := a.toInt(deposit)
+	if err != nil {
+		return model.Money{}, err
+	}
+	legacy := make([]LegacyHold, 0, len(holds))
+	for _, h := range holds {
+		n, err := a.toInt(h.Amount)
+		if err != nil {
+			return model.Money{}, err
+		}
+		legacy = append(legacy, LegacyHold{Amount: n, ReleaseAt: h.ReleaseAt})
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return keeper.DepositWithHolds(accountID, amount, legacy)
+	}))
+}
+
+func (a *accountAdapter) DebitWithCheck(ctx context.Context, accountID string, debit Debit) (model.Money, error) {
+	debiter, ok := a.svc.(OverdraftDebiter)
+	if !ok {
+		if !a.checkThenWithdraw {
+			return model.Money{}, errorcode.ErrUnsupported
+		}
+
+		return a.withdrawAfterCheck(ctx, accountID, debit)
+	}
+
+	amount, err := a.toInt(debit.Amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+	var fee, limit int
+	if !debit.Fee.IsZero() {
+		if fee, err = a.toInt(debit.Fee); err != nil {
+			return model.Money{}, err
+		}
+	}
+	var sweepFrom string
+	switch debit.Policy.Kind {
+	case overdraft.FixedLimit:
+		if !debit.Policy.Limit.IsZero() {
+			if limit, err = a.toInt(debit.Policy.Limit); err != nil {
+				return model.Money{}, err
+			}
+		}
+	case overdraft.LinkedSweep:
+		sweepFrom = debit.Policy.LinkedAccountID
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return debiter.DebitWithCheck(accountID, amount, fee, limit, sweepFrom)
+	}))
+}
+
+// withdrawAfterCheck stands in for DebitWithCheck when
+// AccountAdapterOptions.CheckThenWithdraw is set. It is not atomic; see
+// there.
+func (a *accountAdapter) withdrawAfterCheck(ctx context.Context, accountID string, debit Debit) (model.Money, error) {
+	if debit.Policy.Kind != overdraft.None {
+		return model.Money{}, errorcode.ErrUnsupported
+	}
+
+	total := debit.Amount
+	if !debit.Fee.IsZero() {
+		var err error
+		if total, err = total.Add(debit.Fee); err != nil {
+			return model.Money{}, err
+		}
+	}
+	balances, err := a.GetBalances(ctx, accountID)
+	if err != nil {
+		return model.Money{}, err
+	}
+	if _, err := debit.Policy.Decide(balances.Available, model.Money{}, total); err != nil {
+		return model.Money{}, err
+	}
+
+	return a.Withdraw(ctx, accountID, debit.Amount)
+}
+
+func (a *accountAdapter) Reverse(ctx context.Context, accountID string, amount model.Money) (model.Money, error) {
+	reverser, ok := a.svc.(Reverser)
+	if !ok {
+		return model.Money{}, errorcode.ErrUnsupported
+	}
+
+	n, err := a.toInt(amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return reverser.ReverseWithdrawal(accountID, n)
+	}))
+}
+
+func (a *accountAdapter) DepositCheque(ctx context.Context, accountID string, cheque Cheque) (Hold, error) {
+	depositor, ok := a.svc.(ChequeDepositor)
+	if !ok {
+		return Hold{}, errorcode.ErrUnsupported
+	}
+
+	n, err := a.toInt(cheque.Amount)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	holdID, err := call(ctx, func() (string, error) {
+		return depositor.DepositCheque(accountID, cheque.MICR.String(), n)
+	})
+	if err != nil {
+		return Hold{}, err
+	}
+
+	return Hold{ID: holdID, Amount: cheque.Amount}, nil
+}
+
+func (a *accountAdapter) ChargeFee(ctx context.Context, accountID string, fee Fee) (model.Money, error) {
+	charger, ok := a.svc.(FeeCharger)
+	if !ok {
+		return model.Money{}, errorcode.ErrUnsupported
+	}
+
+	n, err := a.toInt(fee.Amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return a.money(call(ctx, func() (int, error) {
+		return charger.ChargeFee(accountID, fee.Description, n)
+	}))
+}
+
+func (a *accountAdapter) toInt(m model.Money) (int, error) {
+	if m.Currency != a.currency {
+		return 0, errorcode.Wrap(errorcode.CurrencyMismatch, fmt.Errorf("%s and %s", m.Currency, a.currency))
+	}
+	if int64(int(m.Amount)) != m.Amount {
+		return 0, errorcode.Wrap(errorcode.AmountOverflow, fmt.Errorf("%s", m))
+	}
+
+	return int(m.Amount), nil
+}
+
+func (a *accountAdapter) money(amount int, err error) (model.Money, error) {
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return model.NewMoney(int64(amount), a.currency), nil
+}
+
+type cardAdapter struct {
+	svc CardInterface
+}
+
+func (a *cardAdapter) InsertCard(ctx context.Context, card model.Card) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, a.svc.InsertCard(card)
+	})
+	return err
+}
+
+func (a *cardAdapter) RemoveCard(ctx context.Context) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, a.svc.RemoveCard()
+	})
+	return err
+}
+
+func (a *cardAdapter) RetainCard(ctx context.Context) error {
+	retainer, ok := a.svc.(CardRetainer)
+	if !ok {
+		return errorcode.ErrUnsupported
+	}
+
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, retainer.RetainCard()
+	})
+	return err
+}
+
+func call[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v: v, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
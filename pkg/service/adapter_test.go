@@ -0,0 +1,318 @@
+package service_test
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/internal/testutil"
+	"atm/pkg/micr"
+	"atm/pkg/model"
+	"atm/pkg/overdraft"
+	"atm/pkg/pinblock"
+	"atm/pkg/service"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type blockingCardSvc struct {
+	release chan struct{}
+}
+
+func (b *blockingCardSvc) InsertCard(card model.Card) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingCardSvc) RemoveCard() error {
+	<-b.release
+	return nil
+}
+
+func TestAdaptAccount(t *testing.T) {
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		AccountIDs:    []string{"test_account_1"},
+		GetBalanceAmt: 50,
+	}), testutil.NewAccountAdapterOptions())
+
+	accountIDs, err := svc.GetAccountIDs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"test_account_1"}, accountIDs)
+
+	balance, err := svc.GetBalance(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(50, testutil.Currency), balance)
+}
+
+func TestAdaptAccountMoney(t *testing.T) {
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		BalanceAfterDeposit: 1250,
+	}), testutil.NewAccountAdapterOptions())
+
+	balance, err := svc.MakeDeposit(context.Background(), "test_account_1", model.NewMoney(250, testutil.Currency))
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(1250, testutil.Currency), balance)
+
+	_, err = svc.MakeDeposit(context.Background(), "test_account_1", model.NewMoney(250, "EUR"))
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+	_, err = svc.Withdraw(context.Background(), "test_account_1", model.NewMoney(250, "EUR"))
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+}
+
+func TestAdaptAccountReverse(t *testing.T) {
+	var reversed int
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		BalanceAfterReverse: 1000,
+		Reversed:            &reversed,
+	}), testutil.NewAccountAdapterOptions())
+
+	balance, err := svc.Reverse(context.Background(), "test_account_1", model.NewMoney(400, testutil.Currency))
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(1000, testutil.Currency), balance)
+	require.Equal(t, 400, reversed)
+
+	legacy := struct{ service.AccountInterface }{testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{})}
+	_, err = service.AdaptAccount(legacy, testutil.NewAccountAdapterOptions()).
+		Reverse(context.Background(), "test_account_1", model.NewMoney(400, testutil.Currency))
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+}
+
+func TestAdaptAccountDebitWithCheck(t *testing.T) {
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		GetBalanceAmt:        1000,
+		BalanceAfterWithdraw: -400,
+	}), testutil.NewAccountAdapterOptions())
+
+	balance, err := svc.DebitWithCheck(context.Background(), "test_account_1", service.Debit{
+		Amount: model.NewMoney(1300, testutil.Currency),
+		Fee:    model.NewMoney(100, testutil.Currency),
+		Policy: overdraft.Policy{Kind: overdraft.FixedLimit, Limit: model.NewMoney(400, testutil.Currency)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(-400, testutil.Currency), balance)
+
+	_, err = svc.DebitWithCheck(context.Background(), "test_account_1", service.Debit{
+		Amount: model.NewMoney(1001, testutil.Currency),
+	})
+	require.ErrorIs(t, err, errorcode.ErrIsOverdraw)
+}
+
+func TestAdaptAccountDebitWithoutDebiter(t *testing.T) {
+	var withdrawn bool
+	legacy := &legacyWithdrawer{
+		AccountInterface: testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+			GetBalanceAmt:        1000,
+			BalanceAfterWithdraw: 100,
+		}),
+		withdrawn: &withdrawn,
+	}
+	debit := service.Debit{Amount: model.NewMoney(900, testutil.Currency)}
+
+	_, err := service.AdaptAccount(legacy, testutil.NewAccountAdapterOptions()).
+		DebitWithCheck(context.Background(), "test_account_1", debit)
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+	require.False(t, errorcode.IsRetryable(err))
+	require.False(t, withdrawn)
+
+	opts := testutil.NewAccountAdapterOptions()
+	opts.CheckThenWithdraw = true
+	svc := service.AdaptAccount(legacy, opts)
+
+	balance, err := svc.DebitWithCheck(context.Background(), "test_account_1", service.Debit{
+		Amount: model.NewMoney(900, testutil.Currency),
+		Fee:    model.NewMoney(100, testutil.Currency),
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(100, testutil.Currency), balance)
+	require.True(t, withdrawn)
+
+	withdrawn = false
+	_, err = svc.DebitWithCheck(context.Background(), "test_account_1", service.Debit{
+		Amount: model.NewMoney(901, testutil.Currency),
+		Fee:    model.NewMoney(100, testutil.Currency),
+	})
+	require.ErrorIs(t, err, errorcode.ErrIsOverdraw)
+	require.False(t, errorcode.IsRetryable(err))
+	require.False(t, withdrawn)
+
+	_, err = svc.DebitWithCheck(context.Background(), "test_account_1", service.Debit{
+		Amount: model.NewMoney(400, testutil.Currency),
+		Policy: overdraft.Policy{Kind: overdraft.FixedLimit, Limit: model.NewMoney(400, testutil.Currency)},
+	})
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+	require.False(t, errorcode.IsRetryable(err))
+	require.False(t, withdrawn)
+}
+
+// legacyWithdrawer is a legacy service with no optional interfaces that
+// notes whether Withdraw was called.
+type legacyWithdrawer struct {
+	service.AccountInterface
+	withdrawn *bool
+}
+
+func (l *legacyWithdrawer) Withdraw(accountID string, withdrawAmount int) (int, error) {
+	*l.withdrawn = true
+	return l.AccountInterface.Withdraw(accountID, withdrawAmount)
+}
+
+func TestAdaptAccountError(t *testing.T) {
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		ErrOnSelectAccountID: true,
+	}), testutil.NewAccountAdapterOptions())
+
+	err := svc.SelectAccountID(context.Background(), "test_account_1")
+	require.EqualError(t, err, "failed to select accountID")
+}
+
+func TestAdaptCardCancelled(t *testing.T) {
+	svc := service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := svc.InsertCard(ctx, model.Card{HolderName: "test user", Number: "1234"})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAdaptCardDeadline(t *testing.T) {
+	legacy := &blockingCardSvc{release: make(chan struct{})}
+	defer close(legacy.release)
+	svc := service.AdaptCard(legacy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := svc.RemoveCard(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAdaptCardRetain(t *testing.T) {
+	svc := service.AdaptCard(testutil.NewDummyCardSvc(testutil.DummyCardTestOptions{}))
+	require.NoError(t, svc.RetainCard(context.Background()))
+
+	legacy := &blockingCardSvc{release: make(chan struct{})}
+	close(legacy.release)
+	svc = service.AdaptCard(legacy)
+	require.ErrorIs(t, svc.RetainCard(context.Background()), errorcode.ErrUnsupported)
+}
+
+func TestAdaptAccountVerifyPin(t *testing.T) {
+	pinCipher := testutil.NewPinCipher()
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		ValidPinNumber: "4321",
+	}), service.AccountAdapterOptions{PinCipher: pinCipher})
+
+	for pin, expected := range map[string]bool{"4321": true, "1234": false} {
+		block, err := pinCipher.Encrypt(pin, "4111111111111111")
+		require.NoError(t, err)
+
+		isValid, err := svc.VerifyPin(context.Background(), service.EncryptedPin{
+			PAN:      "4111111111111111",
+			Format:   pinCipher.Format(),
+			PinBlock: block,
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, isValid)
+	}
+
+	_, err := svc.VerifyPin(context.Background(), service.EncryptedPin{
+		PAN:    "4111111111111111",
+		Format: pinblock.Format4,
+	})
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+
+	_, err = service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{}), service.AccountAdapterOptions{}).
+		VerifyPin(context.Background(), service.EncryptedPin{})
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+}
+
+func TestAdaptAccountDepositCheque(t *testing.T) {
+	var deposited string
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		DepositedCheque: &deposited,
+	}), testutil.NewAccountAdapterOptions())
+
+	line, err := micr.Parse("⑆123456780⑆ 12345678⑈ 0402")
+	require.NoError(t, err)
+
+	hold, err := svc.DepositCheque(context.Background(), "test_account_1", service.Cheque{
+		MICR:   line,
+		Amount: model.NewMoney(12500, testutil.Currency),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "hold-1", hold.ID)
+	require.Equal(t, model.NewMoney(12500, testutil.Currency), hold.Amount)
+	require.Equal(t, line.String(), deposited)
+}
+
+func TestAdaptAccountChargeFee(t *testing.T) {
+	var charged []string
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		BalanceAfterFee: 9750,
+		ChargedFees:     &charged,
+	}), testutil.NewAccountAdapterOptions())
+
+	balance, err := svc.ChargeFee(context.Background(), "test_account_1", service.Fee{
+		Description: "withdrawal fee",
+		Amount:      model.NewMoney(250, testutil.Currency),
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(9750, testutil.Currency), balance)
+	require.Equal(t, []string{"withdrawal fee"}, charged)
+
+	_, err = svc.ChargeFee(context.Background(), "test_account_1", service.Fee{
+		Description: "withdrawal fee",
+		Amount:      model.NewMoney(250, "EUR"),
+	})
+	require.ErrorIs(t, err, errorcode.ErrCurrencyMismatch)
+}
+
+func TestAdaptAccountBalances(t *testing.T) {
+	release := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	var deposited []service.LegacyHold
+	svc := service.AdaptAccount(testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		GetBalanceAmt:       5000,
+		Holds:               []service.LegacyHold{{ID: "hold-1", Amount: 2000, ReleaseAt: release}},
+		BalanceAfterDeposit: 8000,
+		DepositedHolds:      &deposited,
+	}), testutil.NewAccountAdapterOptions())
+
+	balances, err := svc.GetBalances(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, service.Balances{
+		Ledger:    model.NewMoney(5000, testutil.Currency),
+		Available: model.NewMoney(3000, testutil.Currency),
+		Holds:     []service.Hold{{ID: "hold-1", Amount: model.NewMoney(2000, testutil.Currency), ReleaseAt: release}},
+	}, balances)
+
+	balance, err := svc.DepositWithHolds(context.Background(), "test_account_1", model.NewMoney(3000, testutil.Currency), []service.Hold{
+		{Amount: model.NewMoney(1000, testutil.Currency), ReleaseAt: release},
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(8000, testutil.Currency), balance)
+	require.Equal(t, []service.LegacyHold{{Amount: 1000, ReleaseAt: release}}, deposited)
+}
+
+func TestAdaptAccountBalancesWithoutHolds(t *testing.T) {
+	legacy := struct{ service.AccountInterface }{testutil.NewDummyAccountSvc(testutil.DummyAcctTestOptions{
+		GetBalanceAmt:       5000,
+		BalanceAfterDeposit: 8000,
+	})}
+	svc := service.AdaptAccount(legacy, testutil.NewAccountAdapterOptions())
+
+	balances, err := svc.GetBalances(context.Background(), "test_account_1")
+	require.NoError(t, err)
+	require.Equal(t, service.Balances{
+		Ledger:    model.NewMoney(5000, testutil.Currency),
+		Available: model.NewMoney(5000, testutil.Currency),
+	}, balances)
+
+	balance, err := svc.DepositWithHolds(context.Background(), "test_account_1", model.NewMoney(3000, testutil.Currency), nil)
+	require.NoError(t, err)
+	require.Equal(t, model.NewMoney(8000, testutil.Currency), balance)
+
+	_, err = svc.DepositWithHolds(context.Background(), "test_account_1", model.NewMoney(3000, testutil.Currency), []service.Hold{
+		{Amount: model.NewMoney(1000, testutil.Currency)},
+	})
+	require.ErrorIs(t, err, errorcode.ErrUnsupported)
+}
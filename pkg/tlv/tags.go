@@ -0,0 +1,69 @@
+package tlv
+
+// EMV tags used by the emv package. Names follow EMV Book 3 Annex A.
+const (
+	TagApplicationTemplate     Tag = 0x61
+	TagFCITemplate             Tag = 0x6F
+	TagRecordTemplate          Tag = 0x70
+	TagResponseTemplate2       Tag = 0x77
+	TagAIP                     Tag = 0x82
+	TagDFName                  Tag = 0x84
+	TagTrack2Equivalent        Tag = 0x57
+	TagPAN                     Tag = 0x5A
+	TagTVR                     Tag = 0x95
+	TagTransactionDate         Tag = 0x9A
+	TagTransactionType         Tag = 0x9C
+	TagCardholderName          Tag = 0x5F20
+	TagExpirationDate          Tag = 0x5F24
+	TagEffectiveDate           Tag = 0x5F25
+	TagTransactionCurrencyCode Tag = 0x5F2A
+	TagServiceCode             Tag = 0x5F30
+	TagPANSequenceNumber       Tag = 0x5F34
+	TagAmountAuthorised        Tag = 0x9F02
+	TagAmountOther             Tag = 0x9F03
+	TagIssuerApplicationData   Tag = 0x9F10
+	TagTerminalCountryCode     Tag = 0x9F1A
+	TagApplicationCryptogram   Tag = 0x9F26
+	TagCryptogramInformation   Tag = 0x9F27
+	TagTerminalCapabilities    Tag = 0x9F33
+	TagCVMResults              Tag = 0x9F34
+	TagTerminalType            Tag = 0x9F35
+	TagATC                     Tag = 0x9F36
+	TagUnpredictableNumber     Tag = 0x9F37
+)
+
+var tagNames = map[Tag]string{
+	TagApplicationTemplate:     "Application Template",
+	TagFCITemplate:             "File Control Information Template",
+	TagRecordTemplate:          "READ RECORD Response Message Template",
+	TagResponseTemplate2:       "Response Message Template Format 2",
+	TagAIP:                     "Application Interchange Profile",
+	TagDFName:                  "Dedicated File Name",
+	TagTrack2Equivalent:        "Track 2 Equivalent Data",
+	TagPAN:                     "Application Primary Account Number",
+	TagTVR:                     "Terminal Verification Results",
+	TagTransactionDate:         "Transaction Date",
+	TagTransactionType:         "Transaction Type",
+	TagCardholderName:          "Cardholder Name",
+	TagExpirationDate:          "Application Expiration Date",
+	TagEffectiveDate:           "Application Effective Date",
+	TagTransactionCurrencyCode: "Transaction Currency Code",
+	TagServiceCode:             "Service Code",
+	TagPANSequenceNumber:       "Application PAN Sequence Number",
+	TagAmountAuthorised:        "Amount, Authorised",
+	TagAmountOther:             "Amount, Other",
+	TagIssuerApplicationData:   "Issuer Application Data",
+	TagTerminalCountryCode:     "Terminal Country Code",
+	TagApplicationCryptogram:   "Application Cryptogram",
+	TagCryptogramInformation:   "Cryptogram Information Data",
+	TagTerminalCapabilities:    "Terminal Capabilities",
+	TagCVMResults:              "Cardholder Verification Method Results",
+	TagTerminalType:            "Terminal Type",
+	TagATC:                     "Application Transaction Counter",
+	TagUnpredictableNumber:     "Unpredictable Number",
+}
+
+// Name returns the EMV name of tag, or "" if the tag is not in the dictionary.
+func Name(tag Tag) string {
+	return tagNames[tag]
+}
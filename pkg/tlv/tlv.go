@@ -0,0 +1,182 @@
+// Package tlv encodes and decodes BER-TLV data as used by EMV chip cards.
+package tlv
+
+import (
+	"atm/pkg/errorcode"
+	"fmt"
+)
+
+// Tag is a BER tag with its bytes packed big-endian, so 5F20 is 0x5F20.
+type Tag uint32
+
+// Constructed reports whether values with this tag contain nested TLVs.
+func (t Tag) Constructed() bool {
+	b := t.bytes()
+	return b[0]&0x20 != 0
+}
+
+func (t Tag) String() string {
+	return fmt.Sprintf("%X", t.bytes())
+}
+
+func (t Tag) bytes() []byte {
+	var b []byte
+	for v := uint32(t); v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+
+	return b
+}
+
+// TLV is one decoded data object. Constructed objects have Children and a nil
+// Value; primitive objects have a Value and no Children.
+type TLV struct {
+	Tag      Tag
+	Value    []byte
+	Children []TLV
+}
+
+// Decode parses a sequence of BER-TLV data objects. Padding bytes 00 and FF
+// between objects are skipped.
+func Decode(data []byte) ([]TLV, error) {
+	var out []TLV
+	for pos := 0; pos < len(data); {
+		if data[pos] == 0x00 || data[pos] == 0xFF {
+			pos++
+			continue
+		}
+
+		tag, n, err := decodeTag(data[pos:])
+		if err != nil {
+			return nil, decodeErr(pos, err)
+		}
+		pos += n
+
+		length, n, err := decodeLength(data[pos:])
+		if err != nil {
+			return nil, decodeErr(pos, err)
+		}
+		pos += n
+
+		if length > len(data)-pos {
+			return nil, decodeErr(pos, fmt.Errorf("tag %s length %d exceeds remaining %d bytes", tag, length, len(data)-pos))
+		}
+		value := data[pos : pos+length]
+		pos += length
+
+		obj := TLV{Tag: tag}
+		if tag.Constructed() {
+			obj.Children, err = Decode(value)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			obj.Value = append([]byte(nil), value...)
+		}
+		out = append(out, obj)
+	}
+
+	return out, nil
+}
+
+func decodeErr(offset int, err error) error {
+	return errorcode.Wrap(errorcode.TLVDecodeFail, fmt.Errorf("offset %d: %w", offset, err))
+}
+
+func decodeTag(data []byte) (Tag, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("missing tag")
+	}
+
+	tag := Tag(data[0])
+	n := 1
+	if data[0]&0x1F == 0x1F {
+		for {
+			if n >= len(data) {
+				return 0, 0, fmt.Errorf("truncated tag")
+			}
+			if n >= 4 {
+				return 0, 0, fmt.Errorf("tag longer than 4 bytes")
+			}
+			tag = tag<<8 | Tag(data[n])
+			n++
+			if data[n-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	return tag, n, nil
+}
+
+func decodeLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("missing length")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+
+	n := int(data[0] & 0x7F)
+	if n == 0 || n > 3 {
+		return 0, 0, fmt.Errorf("unsupported length form %02X", data[0])
+	}
+	if len(data) < 1+n {
+		return 0, 0, fmt.Errorf("truncated length")
+	}
+
+	length := 0
+	for _, b := range data[1 : 1+n] {
+		length = length<<8 | int(b)
+	}
+
+	return length, 1 + n, nil
+}
+
+// Encode serialises objs. Constructed objects are encoded from their Children.
+func Encode(objs ...TLV) []byte {
+	var out []byte
+	for _, obj := range objs {
+		value := obj.Value
+		if obj.Tag.Constructed() {
+			value = Encode(obj.Children...)
+		}
+
+		out = append(out, obj.Tag.bytes()...)
+		out = append(out, encodeLength(len(value))...)
+		out = append(out, value...)
+	}
+
+	return out
+}
+
+func encodeLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n <= 0xFF:
+		return []byte{0x81, byte(n)}
+	case n <= 0xFFFF:
+		return []byte{0x82, byte(n >> 8), byte(n)}
+	default:
+		return []byte{0x83, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// Find returns the first object with tag, searching constructed objects
+// depth first.
+func Find(objs []TLV, tag Tag) (TLV, bool) {
+	for _, obj := range objs {
+		if obj.Tag == tag {
+			return obj, true
+		}
+		if found, ok := Find(obj.Children, tag); ok {
+			return found, true
+		}
+	}
+
+	return TLV{}, false
+}
@@ -0,0 +1,85 @@
+package tlv
+
+import (
+	"atm/pkg/errorcode"
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	data := mustHex(t, "70"+"1B"+
+		"5A"+"08"+"4111111111111111"+
+		"5F20"+"08"+"444F452F4A4F484E"+
+		"5F24"+"03"+"251231"+
+		"00FF")
+
+	objs, err := Decode(data)
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	require.Equal(t, TagRecordTemplate, objs[0].Tag)
+	require.True(t, objs[0].Tag.Constructed())
+	require.Len(t, objs[0].Children, 3)
+
+	name, ok := Find(objs, TagCardholderName)
+	require.True(t, ok)
+	require.Equal(t, "DOE/JOHN", string(name.Value))
+	require.Equal(t, "5F20", name.Tag.String())
+	require.Equal(t, "Cardholder Name", Name(name.Tag))
+
+	_, ok = Find(objs, TagApplicationCryptogram)
+	require.False(t, ok)
+}
+
+func TestLongLength(t *testing.T) {
+	value := bytes.Repeat([]byte{0xAB}, 300)
+	encoded := Encode(TLV{Tag: TagIssuerApplicationData, Value: value})
+	require.Equal(t, mustHex(t, "9F1082012C"), encoded[:5])
+
+	objs, err := Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, value, objs[0].Value)
+
+	encoded = Encode(TLV{Tag: TagIssuerApplicationData, Value: value[:200]})
+	require.Equal(t, mustHex(t, "9F1081C8"), encoded[:4])
+}
+
+func TestRoundTrip(t *testing.T) {
+	objs := []TLV{
+		{Tag: TagFCITemplate, Children: []TLV{
+			{Tag: TagDFName, Value: mustHex(t, "A0000000031010")},
+			{Tag: TagApplicationTemplate, Children: []TLV{
+				{Tag: TagPAN, Value: mustHex(t, "5500000000000004")},
+			}},
+		}},
+		{Tag: TagATC, Value: mustHex(t, "0001")},
+	}
+
+	decoded, err := Decode(Encode(objs...))
+	require.NoError(t, err)
+	require.Equal(t, objs, decoded)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, data := range []string{
+		"5A08411111",           // value truncated
+		"9F",                   // tag truncated
+		"5A",                   // length missing
+		"5A84FFFFFFFF",         // 4-byte length
+		"5A80",                 // indefinite length
+		"7004" + "5A05" + "41", // nested value truncated
+	} {
+		_, err := Decode(mustHex(t, data))
+		require.ErrorIs(t, err, errorcode.ErrTLVDecodeFail, data)
+	}
+}
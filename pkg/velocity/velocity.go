@@ -0,0 +1,202 @@
+// Package velocity declines transactions that come too often or add up to
+// too much over a short window for one card, account or terminal.
+package velocity
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Operation string
+
+const (
+	PinEntry   Operation = "pin entry"
+	Deposit    Operation = "deposit"
+	Withdrawal Operation = "withdrawal"
+)
+
+type Subject string
+
+const (
+	Card     Subject = "card"
+	Account  Subject = "account"
+	Terminal Subject = "terminal"
+)
+
+// Rule limits the attempts at one operation by one subject within a
+// sliding window. A zero MaxCount or MaxSum means that measure is not
+// limited.
+type Rule struct {
+	Operation Operation
+	Subject   Subject
+	Window    time.Duration
+	MaxCount  int
+	MaxSum    model.Money
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s per %s in %s", r.Operation, r.Subject, r.Window)
+}
+
+// Attempt is one operation to be checked or recorded.
+type Attempt struct {
+	Operation  Operation
+	Card       model.Card
+	AccountID  string
+	TerminalID string
+	// Amount is zero for PIN entries.
+	Amount model.Money
+	Time   time.Time
+}
+
+func (a Attempt) key(s Subject) string {
+	switch s {
+	case Card:
+		return a.Card.Number
+	case Account:
+		return a.AccountID
+	case Terminal:
+		return a.TerminalID
+	default:
+		return ""
+	}
+}
+
+// Alert is emitted when an attempt is declined.
+type Alert struct {
+	Rule    Rule
+	Attempt Attempt
+	// Count and Sum are what the window would hold with the attempt.
+	Count int
+	Sum   model.Money
+}
+
+type Config struct {
+	Rules []Rule
+	// OnAlert, if set, is called for every declined attempt. It must not
+	// call back into the Checker.
+	OnAlert func(Alert)
+}
+
+// Checker tracks recent attempts. It is safe for concurrent use and may be
+// shared by several controllers to see a card's activity across terminals.
+type Checker struct {
+	mu      sync.Mutex
+	cfg     Config
+	maxAge  time.Duration
+	history map[historyKey][]entry
+}
+
+type historyKey struct {
+	operation Operation
+	subject   Subject
+	key       string
+}
+
+type entry struct {
+	at     time.Time
+	amount model.Money
+}
+
+func New(cfg Config) *Checker {
+	var maxAge time.Duration
+	for _, r := range cfg.Rules {
+		maxAge = max(maxAge, r.Window)
+	}
+
+	return &Checker{
+		cfg:     cfg,
+		maxAge:  maxAge,
+		history: make(map[historyKey][]entry),
+	}
+}
+
+// Check returns an error carrying errorcode.VelocityExceeded, and emits an
+// Alert, if a would break a rule. It does not record a; use CheckAndRecord
+// for an attempt that is going ahead.
+func (c *Checker) Check(a Attempt) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.check(a)
+}
+
+// CheckAndRecord checks a like Check and, if it breaks no rule, records it
+// in the same step, so attempts sharing the Checker cannot all pass before
+// any of them is counted. Call it before running the operation: an attempt
+// counts whether or not the operation then succeeds.
+func (c *Checker) CheckAndRecord(a Attempt) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.check(a); err != nil {
+		return err
+	}
+	c.record(a)
+
+	return nil
+}
+
+func (c *Checker) check(a Attempt) error {
+	for _, r := range c.cfg.Rules {
+		if r.Operation != a.Operation {
+			continue
+		}
+		key := a.key(r.Subject)
+		if key == "" {
+			continue
+		}
+
+		count, sum := 1, a.Amount
+		for _, prev := range c.history[historyKey{a.Operation, r.Subject, key}] {
+			if a.Time.Sub(prev.at) >= r.Window {
+				continue
+			}
+			count++
+			if next, err := sum.Add(prev.amount); err == nil {
+				sum = next
+			}
+		}
+
+		overCount := r.MaxCount > 0 && count > r.MaxCount
+		overSum := false
+		if !r.MaxSum.IsZero() {
+			cmp, err := sum.Cmp(r.MaxSum)
+			if err != nil {
+				return err
+			}
+			overSum = cmp > 0
+		}
+		if overCount || overSum {
+			if c.cfg.OnAlert != nil {
+				c.cfg.OnAlert(Alert{Rule: r, Attempt: a, Count: count, Sum: sum})
+			}
+			return errorcode.Wrap(errorcode.VelocityExceeded, fmt.Errorf("%s: %d attempts, %s", r, count, sum))
+		}
+	}
+
+	return nil
+}
+
+func (c *Checker) record(a Attempt) {
+	for _, s := range []Subject{Card, Account, Terminal} {
+		key := a.key(s)
+		if key == "" {
+			continue
+		}
+		hk := historyKey{a.Operation, s, key}
+		c.history[hk] = c.prune(append(c.history[hk], entry{at: a.Time, amount: a.Amount}), a.Time)
+	}
+}
+
+func (c *Checker) prune(entries []entry, now time.Time) []entry {
+	i := 0
+	for i < len(entries) && now.Sub(entries[i].at) >= c.maxAge {
+		i++
+	}
+
+	return entries[i:]
+}
@@ -0,0 +1,104 @@
+package velocity
+
+import (
+	"atm/pkg/errorcode"
+	"atm/pkg/model"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func usd(amount int64) model.Money {
+	return model.NewMoney(amount, "USD")
+}
+
+var (
+	start = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	card  = model.Card{Number: "4111111111111111"}
+)
+
+func TestCount(t *testing.T) {
+	var alerts []Alert
+	c := New(Config{
+		Rules: []Rule{{Operation: PinEntry, Subject: Card, Window: time.Hour, MaxCount: 3}},
+		OnAlert: func(a Alert) {
+			alerts = append(alerts, a)
+		},
+	})
+
+	for i := range 3 {
+		a := Attempt{Operation: PinEntry, Card: card, TerminalID: "T1", Time: start.Add(time.Duration(i) * time.Minute)}
+		require.NoError(t, c.CheckAndRecord(a))
+	}
+
+	declined := Attempt{Operation: PinEntry, Card: card, TerminalID: "T4", Time: start.Add(10 * time.Minute)}
+	require.ErrorIs(t, c.Check(declined), errorcode.ErrVelocityExceeded)
+	require.Len(t, alerts, 1)
+	require.Equal(t, 4, alerts[0].Count)
+	require.Equal(t, "T4", alerts[0].Attempt.TerminalID)
+
+	// Other cards and operations are unaffected.
+	require.NoError(t, c.Check(Attempt{Operation: PinEntry, Card: model.Card{Number: "5500000000000004"}, Time: declined.Time}))
+	require.NoError(t, c.Check(Attempt{Operation: Withdrawal, Card: card, Time: declined.Time}))
+
+	// The window slides past the first attempt.
+	require.NoError(t, c.Check(Attempt{Operation: PinEntry, Card: card, Time: start.Add(time.Hour)}))
+}
+
+func TestSum(t *testing.T) {
+	c := New(Config{
+		Rules: []Rule{{Operation: Withdrawal, Subject: Account, Window: 10 * time.Minute, MaxSum: usd(50000)}},
+	})
+
+	for i := range 4 {
+		a := Attempt{Operation: Withdrawal, AccountID: "acct", Amount: usd(10000), Time: start.Add(time.Duration(i) * time.Minute)}
+		require.NoError(t, c.CheckAndRecord(a))
+	}
+
+	require.NoError(t, c.Check(Attempt{Operation: Withdrawal, AccountID: "acct", Amount: usd(10000), Time: start.Add(5 * time.Minute)}))
+	require.ErrorIs(t, c.Check(Attempt{Operation: Withdrawal, AccountID: "acct", Amount: usd(10001), Time: start.Add(5 * time.Minute)}), errorcode.ErrVelocityExceeded)
+	require.NoError(t, c.Check(Attempt{Operation: Withdrawal, AccountID: "other", Amount: usd(50000), Time: start.Add(5 * time.Minute)}))
+}
+
+func TestTerminal(t *testing.T) {
+	c := New(Config{
+		Rules: []Rule{{Operation: Deposit, Subject: Terminal, Window: time.Minute, MaxCount: 1}},
+	})
+
+	a := Attempt{Operation: Deposit, Card: card, TerminalID: "T1", Amount: usd(100), Time: start}
+	require.NoError(t, c.CheckAndRecord(a))
+	a.Card = model.Card{Number: "5500000000000004"}
+	require.ErrorIs(t, c.Check(a), errorcode.ErrVelocityExceeded)
+
+	a.TerminalID = ""
+	require.NoError(t, c.Check(a))
+}
+
+func TestCheckAndRecordConcurrent(t *testing.T) {
+	c := New(Config{
+		Rules: []Rule{{Operation: Withdrawal, Subject: Card, Window: time.Hour, MaxCount: 1}},
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.CheckAndRecord(Attempt{Operation: Withdrawal, Card: card, Time: start.Add(time.Duration(i) * time.Second)})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	passed := 0
+	for err := range errs {
+		if err == nil {
+			passed++
+			continue
+		}
+		require.ErrorIs(t, err, errorcode.ErrVelocityExceeded)
+	}
+	require.Equal(t, 1, passed)
+}